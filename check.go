@@ -1,14 +1,9 @@
 package ydisk
 
 import (
-	"bufio"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
-	"strings"
-
-	"github.com/slytomcat/llog"
 )
 
 func notExists(path string) bool {
@@ -21,46 +16,37 @@ func notExists(path string) bool {
 
 // checkDaemon checks that yandex-disk daemon is installed.
 // It reads the provided daemon configuration file and checks existence of synchronized folder
-// and authorization file ('passwd' file). If one of them is not exists then checkDaemon exits
-// from program.
-// It returns the user catalogue that is synchronized by daemon in case of success check.
-func checkDaemon(conf string) (string, string, error) {
-	exe, err := exec.LookPath("yandex-disk")
-	if err != nil {
-		msg := "Yandex.Disk CLI utility is not installed. Install it first"
-		llog.Error(msg)
-		return "", "", fmt.Errorf(msg)
-	}
-	f, err := os.Open(conf)
-	if err != nil {
-		llog.Error("Daemon configuration file opening error:", err)
-		return "", "", err
-	}
-	defer f.Close()
-	reader := bufio.NewReader(f)
-	var line, dir, auth string
-	for {
-		line, err = reader.ReadString('\n')
+// and authorization file ('passwd' file). If one of them is not exists then checkDaemon returns
+// an error.
+// The exe is the daemon executable (or the wrapper command), when it is empty the yandex-disk
+// is searched in PATH. The exe given without path separators is searched in PATH too.
+// It returns the daemon executable and the parsed daemon configuration in case of success check.
+func checkDaemon(conf, exe string, log Logger) (string, *Config, error) {
+	if exe == "" {
+		var err error
+		exe, err = exec.LookPath("yandex-disk")
 		if err != nil {
-			break
+			log.Error(ErrNotInstalled)
+			return "", nil, ErrNotInstalled
 		}
-		if strings.HasPrefix(line, "dir") {
-			dir = line[5 : len(line)-2]
-		}
-		if strings.HasPrefix(line, "auth") {
-			auth = line[6 : len(line)-2]
-		}
-		if dir != "" && auth != "" {
-			break
+	} else {
+		// LookPath checks existence and executability of file when exe contains path separator
+		path, err := exec.LookPath(exe)
+		if err != nil {
+			err = fmt.Errorf("%w: executable %q is not valid: %w", ErrNotInstalled, exe, err)
+			log.Error(err)
+			return "", nil, err
 		}
+		exe = path
 	}
-	if err != nil && err != io.EOF {
-		return "", "", err
+	cfg, err := ReadConfig(conf)
+	if err != nil {
+		log.Error("Daemon configuration file reading error:", err)
+		return "", nil, fmt.Errorf("%w: %w", ErrConfigUnreadable, err)
 	}
-	if notExists(dir) || notExists(auth) {
-		msg := "Daemon is not configured. First run: `yandex-disk setup`"
-		llog.Error(msg)
-		return "", "", fmt.Errorf("%s", msg)
+	if cfg.Dir == "" || cfg.Auth == "" || notExists(cfg.Dir) || notExists(cfg.Auth) {
+		log.Error(ErrNotConfigured)
+		return "", nil, ErrNotConfigured
 	}
-	return exe, dir, nil
+	return exe, cfg, nil
 }
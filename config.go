@@ -0,0 +1,238 @@
+package ydisk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Config - yandex-disk daemon configuration (the content of daemon configuration file)
+type Config struct {
+	Dir         string            // Path to synchronized folder
+	Auth        string            // Path to authorization (passwd) file
+	ExcludeDirs []string          // Folders excluded from synchronization (relative to Dir)
+	Proxy       string            // Proxy settings: "auto", "no" or "protocol,address,port,login,password"
+	ReadOnly    bool              // Do not upload local changes to the server
+	Overwrite   bool              // Overwrite locally changed files in read-only mode
+	Extra       map[string]string // Other (unknown) options
+	lines       []configLine      // Lines of the parsed file (to preserve comments and options order)
+}
+
+// configLine - the line of configuration file: option key or comment/empty line
+type configLine struct {
+	key   string // Option key or "" for comment and empty line
+	value string // Option value as it was parsed
+	raw   string // Original line (without line end)
+}
+
+// Known option keys
+const (
+	keyDir         = "dir"
+	keyAuth        = "auth"
+	keyExcludeDirs = "exclude-dirs"
+	keyProxy       = "proxy"
+	keyReadOnly    = "read-only"
+	keyOverwrite   = "overwrite"
+)
+
+// ReadConfig reads and parses the daemon configuration file.
+func ReadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseConfig(f)
+}
+
+// ParseConfig parses the daemon configuration. The configuration consists of lines
+// `key="value"` (quotes are optional, spaces around `=` are allowed). Empty lines and lines
+// started with `#` are comments. Malformed lines (without `=` or without key) are ignored but
+// they are preserved as comments. The options with wrong values (e.g. read-only="maybe") are
+// ignored too, but their lines are preserved until the option is changed.
+func ParseConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{Extra: map[string]string{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		raw := strings.TrimRight(scanner.Text(), "\r")
+		line := strings.TrimSpace(raw)
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.HasPrefix(line, "#") {
+			cfg.lines = append(cfg.lines, configLine{"", "", raw})
+			continue
+		}
+		value = unquote(strings.TrimSpace(value))
+		cfg.set(key, value) // the flag option with wrong value is false
+		cfg.lines = append(cfg.lines, configLine{key, value, raw})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Set sets the option value from its string representation. The value of flag options
+// (read-only, overwrite) can be "true"/"false" ("" means true). Unknown options are stored
+// in Extra.
+func (c *Config) Set(key, value string) error {
+	return c.set(key, value)
+}
+
+// set sets the option value from its string representation
+func (c *Config) set(key, value string) error {
+	var err error
+	switch key {
+	case keyDir:
+		c.Dir = value
+	case keyAuth:
+		c.Auth = value
+	case keyExcludeDirs:
+		c.ExcludeDirs = splitList(value)
+	case keyProxy:
+		c.Proxy = value
+	case keyReadOnly:
+		c.ReadOnly, err = parseBool(key, value)
+	case keyOverwrite:
+		c.Overwrite, err = parseBool(key, value)
+	default:
+		if c.Extra == nil {
+			c.Extra = map[string]string{}
+		}
+		c.Extra[key] = value
+	}
+	return err
+}
+
+// values returns the current option values in canonical order. Empty options are omitted.
+func (c *Config) values() ([]string, map[string]string) {
+	keys := []string{}
+	vals := map[string]string{}
+	add := func(key, value string, ok bool) {
+		if ok {
+			keys = append(keys, key)
+			vals[key] = value
+		}
+	}
+	add(keyDir, c.Dir, c.Dir != "")
+	add(keyAuth, c.Auth, c.Auth != "")
+	add(keyExcludeDirs, strings.Join(c.ExcludeDirs, ","), len(c.ExcludeDirs) > 0)
+	add(keyProxy, c.Proxy, c.Proxy != "")
+	add(keyReadOnly, "", c.ReadOnly)
+	add(keyOverwrite, "", c.Overwrite)
+	extra := make([]string, 0, len(c.Extra))
+	for k := range c.Extra {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		add(k, c.Extra[k], true)
+	}
+	return keys, vals
+}
+
+// Bytes returns the configuration file content. Comments, order of options and formatting of
+// not changed options are preserved from the parsed file. New options are added to the end.
+func (c *Config) Bytes() []byte {
+	keys, vals := c.values()
+	buf := bytes.Buffer{}
+	done := map[string]bool{}
+	for _, l := range c.lines {
+		if l.key == "" {
+			buf.WriteString(l.raw + "\n")
+			continue
+		}
+		if done[l.key] {
+			continue // duplicated option
+		}
+		done[l.key] = true
+		if v, ok := vals[l.key]; c.unchanged(l, v) {
+			buf.WriteString(l.raw + "\n")
+		} else if ok {
+			fmt.Fprintf(&buf, "%s=\"%s\"\n", l.key, v)
+		}
+	}
+	for _, k := range keys {
+		if !done[k] {
+			fmt.Fprintf(&buf, "%s=\"%s\"\n", k, vals[k])
+		}
+	}
+	return buf.Bytes()
+}
+
+// unchanged reports whether the parsed option line represents the current option value.
+func (c *Config) unchanged(l configLine, value string) bool {
+	switch l.key {
+	case keyReadOnly:
+		b, _ := parseBool(l.key, l.value)
+		return b == c.ReadOnly
+	case keyOverwrite:
+		b, _ := parseBool(l.key, l.value)
+		return b == c.Overwrite
+	case keyExcludeDirs:
+		return strings.Join(splitList(l.value), ",") == value
+	case keyDir, keyAuth, keyProxy:
+		return l.value == value
+	}
+	v, ok := c.Extra[l.key]
+	return ok && v == l.value
+}
+
+// Save atomically writes the configuration to the file: the content is written into temporary
+// file in the same folder and then the temporary file is renamed to path.
+func (c *Config) Save(path string) error {
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // it fails after successful rename
+	if _, err = f.Write(c.Bytes()); err == nil {
+		if err = f.Chmod(mode); err == nil {
+			err = f.Sync()
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func unquote(s string) string {
+	if len(s) > 1 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseBool parses the flag option value. The option without value means true.
+func parseBool(key, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("wrong value of %s option: %q", key, value)
+}
@@ -0,0 +1,99 @@
+package ydisk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader("# comment\r\n" +
+		"proxy=\"no\"\r\n" +
+		"\r\n" +
+		"  auth = \"/home/user/.config/yandex-disk/passwd\"\r\n" +
+		"dir=/home/user/Yandex Disk\n" +
+		"exclude-dirs=\"build, cache,,tmp/cache\"\n" +
+		"read-only=\"\"\n" +
+		"overwrite=\"false\"\n" +
+		"some-option='value'"))
+	require.NoError(t, err)
+	require.Equal(t, "/home/user/Yandex Disk", cfg.Dir)
+	require.Equal(t, "/home/user/.config/yandex-disk/passwd", cfg.Auth)
+	require.Equal(t, []string{"build", "cache", "tmp/cache"}, cfg.ExcludeDirs)
+	require.Equal(t, "no", cfg.Proxy)
+	require.True(t, cfg.ReadOnly)
+	require.False(t, cfg.Overwrite)
+	require.Equal(t, map[string]string{"some-option": "value"}, cfg.Extra)
+	require.Len(t, cfg.lines, 9)
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	// malformed lines and wrong option values are ignored and preserved
+	c := "dir=\"/home/user/Yandex.Disk\"\nwrong line\n=\"value\"\nread-only=\"maybe\"\n"
+	cfg, err := ParseConfig(strings.NewReader(c))
+	require.NoError(t, err)
+	require.Equal(t, "/home/user/Yandex.Disk", cfg.Dir)
+	require.False(t, cfg.ReadOnly)
+	require.Empty(t, cfg.Extra)
+	require.Equal(t, c, string(cfg.Bytes()))
+	// the option with wrong value is replaced when it is changed
+	cfg.ReadOnly = true
+	require.Equal(t, "dir=\"/home/user/Yandex.Disk\"\nwrong line\n=\"value\"\nread-only=\"\"\n", string(cfg.Bytes()))
+}
+
+func TestReadConfigNotExists(t *testing.T) {
+	_, err := ReadConfig(Cfg + "_bad")
+	require.Error(t, err)
+}
+
+func TestConfigSave(t *testing.T) {
+	orig := "# comment\n" +
+		"proxy = no\n" +
+		"auth=\"/home/user/.config/yandex-disk/passwd\"\r\n" +
+		"dir=\"/home/user/Yandex.Disk\"\n" +
+		"exclude-dirs=\"build, cache\"\n" +
+		"overwrite=\"false\"\n" +
+		"\n" +
+		"some-option=\"value\"\n" +
+		"other-option=\"value\"\n"
+	cfg, err := ParseConfig(strings.NewReader(orig))
+	require.NoError(t, err)
+	require.Equal(t, "# comment\n"+
+		"proxy = no\n"+
+		"auth=\"/home/user/.config/yandex-disk/passwd\"\n"+
+		"dir=\"/home/user/Yandex.Disk\"\n"+
+		"exclude-dirs=\"build, cache\"\n"+
+		"overwrite=\"false\"\n"+
+		"\n"+
+		"some-option=\"value\"\n"+
+		"other-option=\"value\"\n", string(cfg.Bytes()))
+	cfg.ExcludeDirs = append(cfg.ExcludeDirs, "tmp")
+	cfg.Proxy = "auto"
+	require.NoError(t, cfg.Set("read-only", "true"))
+	require.NoError(t, cfg.Set("new-option", "new"))
+	delete(cfg.Extra, "some-option")
+	path := filepath.Join(t.TempDir(), "config.cfg")
+	require.NoError(t, cfg.Save(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "# comment\n"+
+		"proxy=\"auto\"\n"+
+		"auth=\"/home/user/.config/yandex-disk/passwd\"\n"+
+		"dir=\"/home/user/Yandex.Disk\"\n"+
+		"exclude-dirs=\"build,cache,tmp\"\n"+
+		"overwrite=\"false\"\n"+
+		"\n"+
+		"other-option=\"value\"\n"+
+		"read-only=\"\"\n"+
+		"new-option=\"new\"\n", string(data))
+	saved, err := ReadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, cfg.ExcludeDirs, saved.ExcludeDirs)
+	require.True(t, saved.ReadOnly)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1) // no temporary files left
+}
@@ -0,0 +1,83 @@
+package ydisk
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotInstalled is returned when the yandex-disk executable is not found or it is not valid
+var ErrNotInstalled = errors.New("Yandex.Disk CLI utility is not installed. Install it first")
+
+// ErrNotConfigured is returned when the synchronized folder or authorization file is not configured
+var ErrNotConfigured = errors.New("Daemon is not configured. First run: `yandex-disk setup`")
+
+// ErrConfigUnreadable is returned when the daemon configuration file can't be read or parsed
+var ErrConfigUnreadable = errors.New("daemon configuration file can't be read")
+
+// ErrTimeout is returned when the yandex-disk command is not completed within the command timeout
+var ErrTimeout = errors.New("command timed out")
+
+// ErrDaemonStartFailed matches the *DaemonError returned when `yandex-disk start` fails
+var ErrDaemonStartFailed = errors.New("daemon start failed")
+
+// ErrDaemonStopFailed matches the *DaemonError returned when `yandex-disk stop` fails
+var ErrDaemonStopFailed = errors.New("daemon stop failed")
+
+// ErrNotInSyncDir is returned when the requested path is outside of the synchronized folder
+var ErrNotInSyncDir = errors.New("path is outside of synchronized folder")
+
+// ErrUnsupported is returned when the operation is not supported by the installed yandex-disk version
+var ErrUnsupported = errors.New("not supported by the installed yandex-disk version")
+
+// ErrDirConflict is returned when two managed daemons use the same synchronized folder
+var ErrDirConflict = errors.New("synchronized folders conflict")
+
+// ErrClosed is returned when the waiting for daemon status can't be completed as YDisk is closed
+var ErrClosed = errors.New("YDisk is closed")
+
+// DaemonError is returned when the yandex-disk CLI command is refused by daemon. The errors of
+// start and stop commands match ErrDaemonStartFailed and ErrDaemonStopFailed (via errors.Is).
+type DaemonError struct {
+	CommandResult       // Captured command result
+	Err           error // Original error
+}
+
+func (e *DaemonError) Error() string {
+	if out := e.Output(); out != "" {
+		return fmt.Sprintf("yandex-disk %s: %v: %s", e.Cmd, e.Err, out)
+	}
+	return fmt.Sprintf("yandex-disk %s: %v", e.Cmd, e.Err)
+}
+
+func (e *DaemonError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DaemonError) Is(target error) bool {
+	switch e.Cmd {
+	case "start":
+		return target == ErrDaemonStartFailed
+	case "stop":
+		return target == ErrDaemonStopFailed
+	}
+	return false
+}
+
+// WaitError is returned when the daemon didn't reach the expected status.
+type WaitError struct {
+	Want Status // Expected status
+	Last YDvals // The last observed status values
+	Err  error  // Reason of waiting interruption
+}
+
+func (e *WaitError) Error() string {
+	msg := fmt.Sprintf("daemon didn't reach %s status (last observed status: %s", e.Want, e.Last.Stat)
+	if e.Last.Err != "" {
+		msg += fmt.Sprintf(", error: %s %s", e.Last.Err, e.Last.ErrP)
+	}
+	return fmt.Sprintf("%s): %v", msg, e.Err)
+}
+
+func (e *WaitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,258 @@
+package ydisk
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Change is the daemon status change received from one of daemons managed by Manager.
+type Change struct {
+	Conf   string // Path to daemon configuration file
+	YDvals        // Daemon status values
+}
+
+// Quota is the disk space values (in bytes) aggregated over several daemons.
+type Quota struct {
+	Total int64 // Total space available
+	Used  int64 // Used space
+	Free  int64 // Free space
+	Trash int64 // Trash size
+}
+
+// Manager manages several YDisk instances (e.g. for several Yandex accounts) keyed by the path
+// of daemon configuration file. Status changes of all managed daemons are multiplexed into
+// Changes channel.
+type Manager struct {
+	Changes chan Change // Output channel for status changes of all managed daemons
+	opts    []Option    // Options for all managed YDisks
+	mu      sync.Mutex  // Protects disks, adding and closed
+	disks   map[string]*managed
+	adding  map[string]string // Synchronized folders of daemons that are being added
+	closed  bool
+}
+
+// managed is the YDisk managed by Manager
+type managed struct {
+	*YDisk
+	done chan struct{} // Closed to stop forwarding of changes
+	fwd  chan struct{} // Closed when forwarding of changes is finished
+	once sync.Once     // Protects closing of done
+}
+
+// NewManager creates new Manager. The opts are applied to all YDisks created by Manager.
+func NewManager(opts ...Option) *Manager {
+	return &Manager{
+		Changes: make(chan Change, 1),
+		opts:    opts,
+		disks:   map[string]*managed{},
+		adding:  map[string]string{},
+	}
+}
+
+// confKey returns the key for daemon configuration file
+func confKey(conf string) string {
+	if abs, err := filepath.Abs(conf); err == nil {
+		return abs
+	}
+	return filepath.Clean(conf)
+}
+
+// nested reports whether one of the paths is the same or inside of other one
+func nested(a, b string) bool {
+	a, b = filepath.Clean(a)+string(filepath.Separator), filepath.Clean(b)+string(filepath.Separator)
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// Add creates new YDisk for the daemon configuration file and starts forwarding of its status
+// changes to Manager.Changes. The opts are applied after the Manager options. Add returns an
+// error wrapping ErrDirConflict when the synchronized folder of new daemon is the same as (or
+// nested with) the synchronized folder of any already managed daemon.
+func (m *Manager) Add(conf string, opts ...Option) (*YDisk, error) {
+	key := confKey(conf)
+	cfg, err := ReadConfig(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigUnreadable, err)
+	}
+	if err := m.reserve(key, cfg.Dir); err != nil {
+		return nil, err
+	}
+	// YDisk creation runs daemon commands, so it is made without lock
+	yd, err := NewYDiskWithOptions(key, append(append([]Option{}, m.opts...), opts...)...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.adding, key)
+	if err != nil {
+		return nil, err
+	}
+	if m.closed {
+		yd.Close()
+		return nil, ErrClosed
+	}
+	d := &managed{YDisk: yd, done: make(chan struct{}), fwd: make(chan struct{})}
+	m.disks[key] = d
+	go m.forward(key, d)
+	return yd, nil
+}
+
+// reserve checks that the daemon can be added and marks it as being added
+func (m *Manager) reserve(key, dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if _, ok := m.disks[key]; ok {
+		return fmt.Errorf("daemon with configuration %s is already managed", key)
+	}
+	if _, ok := m.adding[key]; ok {
+		return fmt.Errorf("daemon with configuration %s is already being added", key)
+	}
+	for k, d := range m.disks {
+		if nested(d.Path, dir) {
+			return fmt.Errorf("%w: %s (%s) and %s (%s)", ErrDirConflict, dir, key, d.Path, k)
+		}
+	}
+	for k, path := range m.adding {
+		if nested(path, dir) {
+			return fmt.Errorf("%w: %s (%s) and %s (%s)", ErrDirConflict, dir, key, path, k)
+		}
+	}
+	m.adding[key] = dir
+	return nil
+}
+
+// forward sends the status changes of managed YDisk to Manager.Changes
+func (m *Manager) forward(key string, d *managed) {
+	defer close(d.fwd)
+	for yds := range d.Changes {
+		select {
+		case m.Changes <- Change{key, yds}:
+		case <-d.done:
+			for range d.Changes { // drain the changes until YDisk is closed
+			}
+			return
+		}
+	}
+}
+
+// Get returns the managed YDisk for daemon configuration file or nil if it is not managed.
+func (m *Manager) Get(conf string) *YDisk {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d, ok := m.disks[confKey(conf)]; ok {
+		return d.YDisk
+	}
+	return nil
+}
+
+// Confs returns the sorted list of configuration files of managed daemons.
+func (m *Manager) Confs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	confs := make([]string, 0, len(m.disks))
+	for k := range m.disks {
+		confs = append(confs, k)
+	}
+	sort.Strings(confs)
+	return confs
+}
+
+// Remove closes the YDisk for daemon configuration file and removes it from Manager.
+func (m *Manager) Remove(conf string) error {
+	key := confKey(conf)
+	m.mu.Lock()
+	d, ok := m.disks[key]
+	delete(m.disks, key)
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("daemon with configuration %s is not managed", key)
+	}
+	d.close()
+	return nil
+}
+
+// close stops forwarding of changes and closes the managed YDisk. It is safe to call close
+// several times.
+func (d *managed) close() {
+	d.once.Do(func() { close(d.done) })
+	d.Close()
+	<-d.fwd
+}
+
+// all returns the managed YDisks in order of their configuration files
+func (m *Manager) all() []*managed {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sorted(m.disks)
+}
+
+// sorted returns the YDisks from map in order of their configuration files
+func sorted(disks map[string]*managed) []*managed {
+	keys := make([]string, 0, len(disks))
+	for k := range disks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]*managed, len(keys))
+	for i, k := range keys {
+		list[i] = disks[k]
+	}
+	return list
+}
+
+// StartAll starts all managed daemons. It returns joined errors of failed starts.
+func (m *Manager) StartAll() error {
+	var errs []error
+	for _, d := range m.all() {
+		if err := d.Start(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.conf, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StopAll stops all managed daemons. It returns joined errors of failed stops.
+func (m *Manager) StopAll() error {
+	var errs []error
+	for _, d := range m.all() {
+		if err := d.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.conf, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Quota returns the disk space values aggregated over all managed daemons. The last status
+// values observed by the event handlers are used, so the not running daemons are not counted.
+func (m *Manager) Quota() Quota {
+	q := Quota{}
+	for _, d := range m.all() {
+		yds := d.Status()
+		q.Total += yds.TotalB
+		q.Used += yds.UsedB
+		q.Free += yds.FreeB
+		q.Trash += yds.TrashB
+	}
+	return q
+}
+
+// Close closes all managed YDisks and Changes channel.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	disks := m.disks
+	m.disks = map[string]*managed{}
+	m.mu.Unlock()
+	for _, d := range sorted(disks) {
+		d.close()
+	}
+	close(m.Changes)
+}
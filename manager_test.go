@@ -0,0 +1,83 @@
+package ydisk
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// managerDaemon creates the fake daemon that reports the status with given total space
+func managerDaemon(t *testing.T, dir, total string) (string, string) {
+	return fakeDaemon(t, dir, "case \"$*\" in\n"+
+		"*--version*) echo \"Yandex.Disk 0.1.6.1080\";;\n"+
+		"*status*) printf \"Synchronization core status: idle\\n\\tTotal: "+total+"\\n"+
+		"\\tUsed: 1 KB\\n\\tAvailable: 2 KB\\n\\tTrash size: 0 B\\n\";;\n"+
+		"esac\n")
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	conf1, exe1 := managerDaemon(t, filepath.Join(dir, "disk1"), "3 KB")
+	conf2, exe2 := managerDaemon(t, filepath.Join(dir, "disk2"), "4 KB")
+	conf3, exe3 := managerDaemon(t, filepath.Join(dir, "disk1", "nested"), "5 KB")
+	m := NewManager(WithPolling(10*time.Millisecond, 10*time.Millisecond, 10*time.Millisecond))
+	yd1, err := m.Add(conf1, WithExecutable(exe1))
+	require.NoError(t, err)
+	_, err = m.Add(conf1, WithExecutable(exe1))
+	require.Error(t, err)
+	_, err = m.Add(conf2, WithExecutable(exe2))
+	require.NoError(t, err)
+	_, err = m.Add(conf3, WithExecutable(exe3))
+	require.True(t, errors.Is(err, ErrDirConflict))
+	_, err = m.Add(conf3+"_bad", WithExecutable(exe3))
+	require.ErrorIs(t, err, ErrConfigUnreadable)
+	require.Equal(t, []string{conf1, conf2}, m.Confs())
+	require.Nil(t, m.Get(conf3))
+	got := map[string]YDvals{}
+	for len(got) < 2 {
+		select {
+		case c := <-m.Changes:
+			got[c.Conf] = c.YDvals
+		case <-time.After(time.Second):
+			t.Fatal("no changes received")
+		}
+	}
+	require.Equal(t, "3 KB", got[conf1].Total)
+	require.Equal(t, "4 KB", got[conf2].Total)
+	require.Equal(t, Quota{Total: 7 * 1024, Used: 2 * 1024, Free: 4 * 1024}, m.Quota())
+	require.NoError(t, m.Remove(conf2))
+	require.Error(t, m.Remove(conf2))
+	require.Equal(t, []string{conf1}, m.Confs())
+	yd1.Close() // closing of managed YDisk doesn't block Manager.Close
+	m.Close()
+	for range m.Changes {
+	}
+	_, err = m.Add(conf2, WithExecutable(exe2))
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestManagerRemoveClose(t *testing.T) {
+	dir := t.TempDir()
+	conf, exe := managerDaemon(t, dir, "3 KB")
+	for i := 0; i < 20; i++ {
+		m := NewManager(WithExecutable(exe))
+		_, err := m.Add(conf)
+		require.NoError(t, err)
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.Remove(conf)
+		}()
+		go func() {
+			defer wg.Done()
+			m.Close()
+		}()
+		wg.Wait()
+		require.Empty(t, m.Confs())
+	}
+}
@@ -0,0 +1,134 @@
+package ydisk
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/slytomcat/llog"
+)
+
+// Option is the option for NewYDiskWithOptions.
+type Option func(*YDisk)
+
+// Logger is the logging interface used by YDisk. It is implemented by *llog.Logger.
+type Logger interface {
+	Debug(v ...interface{})
+	Debugf(f string, v ...interface{})
+	Info(v ...interface{})
+	Warning(v ...interface{})
+	Error(v ...interface{})
+}
+
+// DefaultLogger is the Logger of YDisks created without WithLogger option. It writes to stderr
+// with WARNING level, use its SetLevel, SetFlags and SetOutput methods to change it.
+var DefaultLogger = llog.New(os.Stderr, "", log.LstdFlags, llog.WARNING)
+
+// WithRunner sets the Runner that executes yandex-disk CLI commands.
+func WithRunner(r Runner) Option {
+	return func(yd *YDisk) {
+		yd.runner = r
+	}
+}
+
+// WithClock sets the Clock that provides timers for the status polling.
+func WithClock(c Clock) Option {
+	return func(yd *YDisk) {
+		yd.clock = c
+	}
+}
+
+// WithLogger sets the Logger (DefaultLogger is used by default).
+func WithLogger(l Logger) Option {
+	return func(yd *YDisk) {
+		yd.log = l
+	}
+}
+
+// WithPolling sets the status polling intervals. The interval is continuously increased
+// from the first to the last one (1s and 32s by default) while the daemon status is not changed.
+// The busy interval (2s by default) is used while the daemon is in busy or index status.
+func WithPolling(first, last, busy time.Duration) Option {
+	return func(yd *YDisk) {
+		yd.minPoll, yd.maxPoll, yd.busyPoll = first, last, busy
+	}
+}
+
+// WithChangesBuffer sets the buffer size of Changes channel (1 by default).
+func WithChangesBuffer(size int) Option {
+	return func(yd *YDisk) {
+		yd.changesBuf = size
+	}
+}
+
+// Delivery is the delivery mode of status changes to Changes channel.
+type Delivery int
+
+const (
+	// DeliverAll delivers every change. The event handler waits for the consumer when the
+	// Changes buffer is full (status polling is suspended while it waits).
+	DeliverAll Delivery = iota
+	// DeliverLatest never blocks the event handler: when the Changes buffer is full the oldest
+	// not consumed change is dropped, so the consumer always receives the latest status.
+	// The number of dropped changes is reported by YDisk.Dropped.
+	DeliverLatest
+)
+
+// WithDelivery sets the delivery mode of Changes (DeliverAll by default).
+func WithDelivery(d Delivery) Option {
+	return func(yd *YDisk) {
+		yd.delivery = d
+	}
+}
+
+// WithCommandTimeout sets the timeout for every yandex-disk CLI command (30s by default, 0 means
+// no timeout). The command (with all processes of its process group) is killed on timeout and
+// the error wrapping ErrTimeout is returned. The status command timeout is reported as StatusTimeout.
+func WithCommandTimeout(d time.Duration) Option {
+	return func(yd *YDisk) {
+		yd.cmdTimeout = d
+	}
+}
+
+// WithExecutable sets the path to yandex-disk executable (it is searched in PATH by default).
+// The args are the fixed arguments that are passed before any daemon command. They allow to use
+// a wrapper command, e.g. WithExecutable("flatpak", "run", "--command=yandex-disk", "<app-id>").
+// The executable given without path separators is searched in PATH.
+func WithExecutable(path string, args ...string) Option {
+	return func(yd *YDisk) {
+		yd.exe, yd.exeArgs = path, args
+	}
+}
+
+// WithOutputLanguage sets the language (locale name like "en_US.UTF-8") of Output. The current
+// user language is used by default.
+func WithOutputLanguage(lang string) Option {
+	return func(yd *YDisk) {
+		yd.lang = lang
+	}
+}
+
+// WithSupervisor enables the supervisor that restarts the daemon when it is stopped unexpectedly
+// (not by YDisk.Stop). The restart attempts are made with exponential backoff and their number
+// within the time window is limited: when the limit is reached the attempts are paused until the
+// oldest one leaves the window. The supervisor actions are reported via YDisk.SupervisorEvents.
+// The supervisor detects the daemon stop via the event handler, so the event handler must not be
+// blocked by the not consumed Changes: either read Changes or use WithDelivery(DeliverLatest)
+// when nobody reads it (e.g. on headless hosts).
+func WithSupervisor(cfg SupervisorConfig) Option {
+	return func(yd *YDisk) {
+		if cfg.MinBackoff <= 0 {
+			cfg.MinBackoff = time.Second
+		}
+		if cfg.MaxBackoff < cfg.MinBackoff {
+			cfg.MaxBackoff = max(time.Minute, cfg.MinBackoff)
+		}
+		if cfg.MaxRestarts <= 0 {
+			cfg.MaxRestarts = 5
+		}
+		if cfg.Window <= 0 {
+			cfg.Window = 10 * time.Minute
+		}
+		yd.supervisor = &cfg
+	}
+}
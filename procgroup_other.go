@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ydisk
+
+import "os/exec"
+
+// setProcessGroup does nothing: only the command process is killed when the context is done.
+func setProcessGroup(*exec.Cmd) {}
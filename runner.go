@@ -0,0 +1,99 @@
+package ydisk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Runner executes the external commands (yandex-disk CLI).
+type Runner interface {
+	// Run executes the command and returns its standard output and standard error.
+	Run(ctx context.Context, name string, args ...string) (stdout, stderr []byte, err error)
+}
+
+// CommandResult is the result of yandex-disk CLI command execution.
+type CommandResult struct {
+	Cmd      string // Daemon command (start, stop, publish, etc.)
+	Stdout   string // Standard output of command
+	Stderr   string // Standard error of command
+	ExitCode int    // Exit code of command (-1 when the command was not exited normally)
+}
+
+func newCommandResult(cmd string, stdout, stderr []byte, err error) CommandResult {
+	code := 0
+	if err != nil {
+		code = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+	}
+	return CommandResult{cmd, strings.TrimSpace(string(stdout)), strings.TrimSpace(string(stderr)), code}
+}
+
+// Output returns the standard output and standard error of command joined by new line.
+func (r CommandResult) Output() string {
+	return strings.TrimSpace(r.Stdout + "\n" + r.Stderr)
+}
+
+func (r CommandResult) String() string {
+	return fmt.Sprintf("yandex-disk %s: exit code %d, stdout: %q, stderr: %q", r.Cmd, r.ExitCode, r.Stdout, r.Stderr)
+}
+
+// Clock creates the timers for event handler and provides the current time.
+type Clock interface {
+	NewTimer(d time.Duration) Timer
+	Now() time.Time
+}
+
+// Timer is the interface of timer created by Clock (see time.Timer).
+type Timer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// execRunner is the default Runner that uses os/exec package.
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd) // kill the whole process group when the context is done
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// realClock is the default Clock that uses time package.
+type realClock struct{}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package ydisk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRunnerKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := execRunner{}.Run(ctx, "sh", "-c", "sleep 10 & echo $! > "+pidFile+"; wait")
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+	data, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	// the child process is killed with the whole process group (it may remain a zombie
+	// until it is reaped by init process)
+	require.Eventually(t, func() bool {
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,40 @@
+package ydisk
+
+// Status - the daemon status
+type Status string
+
+// Daemon statuses. The StatusNone, StatusUnknown, StatusUnavailable and StatusTimeout are the library own statuses, the rest ones
+// are reported by daemon. Any not recognised status text is reported as StatusUnrecognised (the
+// original status text is available in YDvals.StatRaw).
+const (
+	StatusUnknown      Status = "unknown"      // Status was not obtained yet
+	StatusNone         Status = "none"         // Daemon is not started
+	StatusUnavailable  Status = "unavailable"  // Status command failed (the error is in YDvals.Err)
+	StatusTimeout      Status = "timeout"      // Status command was not completed in time
+	StatusIdle         Status = "idle"         // Daemon is waiting for changes
+	StatusBusy         Status = "busy"         // Synchronization is in progress
+	StatusIndex        Status = "index"        // Daemon is indexing the synchronized folder
+	StatusPaused       Status = "paused"       // Synchronization is paused
+	StatusError        Status = "error"        // Daemon reported an error (see YDvals.Err and YDvals.ErrP)
+	StatusNoNet        Status = "no_net"       // There is no internet access
+	StatusUnrecognised Status = "unrecognised" // Daemon reported not recognised status
+)
+
+// ParseStatus converts the status text from the daemon output into Status value.
+func ParseStatus(raw string) Status {
+	switch raw {
+	case "idle":
+		return StatusIdle
+	case "busy":
+		return StatusBusy
+	case "index":
+		return StatusIndex
+	case "paused":
+		return StatusPaused
+	case "error":
+		return StatusError
+	case "no_net", "no internet access":
+		return StatusNoNet
+	}
+	return StatusUnrecognised
+}
@@ -0,0 +1,104 @@
+package ydisk
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Subscription is the independent stream of daemon status changes (see YDisk.Subscribe).
+type Subscription struct {
+	C        <-chan YDvals // Channel for status changes. It is closed on Unsubscribe and YDisk.Close.
+	ch       chan YDvals
+	delivery Delivery
+	dropped  atomic.Int64
+	yd       *YDisk
+	done     chan struct{} // Closed on Unsubscribe to release the waiting delivery
+	once     sync.Once
+	mu       sync.Mutex // Protects the sending to ch and closed
+	closed   bool
+}
+
+func newSubscription(yd *YDisk, ch chan YDvals, d Delivery) *Subscription {
+	if d == DeliverLatest && cap(ch) == 0 {
+		ch = make(chan YDvals, 1) // the latest value is kept in the buffer
+	}
+	return &Subscription{C: ch, ch: ch, delivery: d, yd: yd, done: make(chan struct{})}
+}
+
+// Subscribe returns new subscription for daemon status changes. Every subscription receives
+// all changes observed by the event handler via its own channel with given buffer size and
+// delivery mode. Note that the subscription with DeliverAll mode suspends the event handler
+// (and so the delivery to other subscriptions) until its consumer receives the change.
+// The subscription made after Close has the closed channel.
+func (yd *YDisk) Subscribe(buffer int, d Delivery) *Subscription {
+	s := newSubscription(yd, make(chan YDvals, buffer), d)
+	yd.mu.Lock()
+	defer yd.mu.Unlock()
+	if yd.closed {
+		s.close()
+	} else {
+		yd.subs = append(yd.subs, s)
+	}
+	return s
+}
+
+// Unsubscribe stops the delivery of changes and closes the subscription channel.
+func (s *Subscription) Unsubscribe() {
+	s.yd.mu.Lock()
+	for i, v := range s.yd.subs {
+		if v == s {
+			s.yd.subs = append(s.yd.subs[:i:i], s.yd.subs[i+1:]...)
+			break
+		}
+	}
+	s.yd.mu.Unlock()
+	s.once.Do(func() { close(s.done) })
+	s.close()
+}
+
+// Dropped returns the number of intermediate status changes that were replaced by the later
+// ones before the consumer received them (in DeliverLatest mode).
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// close closes the subscription channel
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// deliver sends the status values to the subscription according to its delivery mode. It returns
+// false when the Close is called while waiting for the consumer.
+func (s *Subscription) deliver(yds YDvals) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	if s.delivery == DeliverLatest {
+		for {
+			select {
+			case s.ch <- yds:
+				return true
+			default:
+			}
+			select {
+			case <-s.ch: // replace the not consumed value by the latest one
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	}
+	select {
+	case s.ch <- yds:
+	case <-s.done:
+	case <-s.yd.exit:
+		return false
+	}
+	return true
+}
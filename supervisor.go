@@ -0,0 +1,204 @@
+package ydisk
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errStopped is returned by supervised start when the daemon is stopped by Stop
+var errStopped = errors.New("daemon is stopped by Stop")
+
+// SupervisorConfig is the configuration of supervisor that restarts the daemon after its
+// unexpected stop (see WithSupervisor). Zero values are replaced by defaults.
+type SupervisorConfig struct {
+	MinBackoff  time.Duration // Delay before the first restart attempt (1s by default)
+	MaxBackoff  time.Duration // Maximal delay between restart attempts (1m by default)
+	MaxRestarts int           // Maximal number of restart attempts within Window (5 by default)
+	Window      time.Duration // Time window for restart attempts counting (10m by default)
+}
+
+// SupervisorAction is the kind of supervisor event
+type SupervisorAction string
+
+// Supervisor actions
+const (
+	SupervisorCrash         SupervisorAction = "crash"          // Daemon was stopped unexpectedly
+	SupervisorRestart       SupervisorAction = "restart"        // Restart attempt is scheduled after Delay
+	SupervisorRestarted     SupervisorAction = "restarted"      // Daemon was restarted successfully
+	SupervisorRestartFailed SupervisorAction = "restart_failed" // Restart attempt failed (see Err)
+	SupervisorRecovered     SupervisorAction = "recovered"      // Daemon was started by someone else while waiting
+	SupervisorGaveUp        SupervisorAction = "gave_up"        // Restart attempts limit is reached, restarts are paused
+)
+
+// SupervisorEvent describes the supervisor action.
+type SupervisorEvent struct {
+	Action  SupervisorAction // What supervisor did
+	Attempt int              // Restart attempt number within the window
+	Delay   time.Duration    // Delay before the restart attempt (for SupervisorRestart)
+	Err     error            // Restart error (for SupervisorRestartFailed)
+}
+
+// SupervisorEvents returns the channel of supervisor events (nil when the supervisor is not
+// enabled). The channel is closed on Close. The events are dropped when the channel buffer is full.
+func (yd *YDisk) SupervisorEvents() <-chan SupervisorEvent {
+	return yd.supEvents
+}
+
+// startSupervisor starts the supervisor when it is enabled. It have to be called before the
+// event handler start to not miss any change.
+func (yd *YDisk) startSupervisor() {
+	if yd.supervisor == nil {
+		return
+	}
+	yd.supEvents = make(chan SupervisorEvent, 16)
+	go yd.supervise(yd.Subscribe(1, DeliverLatest))
+}
+
+// running reports whether the status means that the daemon is running
+func running(stat Status) bool {
+	switch stat {
+	case StatusUnknown, StatusNone, StatusUnavailable, StatusTimeout:
+		return false
+	}
+	return true
+}
+
+// supervise watches for the unexpected daemon stops and restarts it
+func (yd *YDisk) supervise(sub *Subscription) {
+	yd.log.Debug("Supervisor started")
+	defer func() {
+		close(yd.supEvents)
+		yd.log.Debug("Supervisor exited")
+	}()
+	wasRunning := false
+	restarts := []time.Time{} // restart attempts within the window
+	for yds := range sub.C {
+		if running(yds.Stat) {
+			wasRunning = true
+			continue
+		}
+		if yds.Stat != StatusNone || !wasRunning {
+			continue
+		}
+		wasRunning = false
+		// recheck the status as the daemon can be already restarted by restart of YDisk. The
+		// status is checked directly to not depend on the event handler.
+		if stopped, out := yd.checkStopped(); stopped {
+			continue // stopped by Stop
+		} else if out.state == outputRunning {
+			wasRunning = true
+			continue
+		}
+		yd.emit(SupervisorEvent{Action: SupervisorCrash})
+		ok, closed := yd.recover(sub, &restarts)
+		if closed {
+			return
+		}
+		wasRunning = ok
+	}
+}
+
+// recover makes the restart attempts until success. When the limit of attempts is reached the
+// attempts are paused until the oldest one leaves the window. It returns true when the daemon is
+// running and closed=true when the subscription is closed.
+func (yd *YDisk) recover(sub *Subscription, restarts *[]time.Time) (ok, closed bool) {
+	cfg := yd.supervisor
+	for {
+		now := yd.clock.Now()
+		for len(*restarts) > 0 && now.Sub((*restarts)[0]) >= cfg.Window {
+			*restarts = (*restarts)[1:]
+		}
+		attempt := len(*restarts) + 1
+		if attempt > cfg.MaxRestarts {
+			yd.emit(SupervisorEvent{Action: SupervisorGaveUp, Attempt: attempt - 1})
+			started, closed := yd.wait(sub, (*restarts)[0].Add(cfg.Window).Sub(now))
+			if started {
+				yd.emit(SupervisorEvent{Action: SupervisorRecovered, Attempt: attempt - 1})
+			}
+			if started || closed {
+				return started, closed
+			}
+			continue
+		}
+		delay := cfg.MinBackoff << (attempt - 1)
+		if delay > cfg.MaxBackoff || delay <= 0 {
+			delay = cfg.MaxBackoff
+		}
+		yd.emit(SupervisorEvent{Action: SupervisorRestart, Attempt: attempt, Delay: delay})
+		started, closed := yd.wait(sub, delay)
+		if started {
+			yd.emit(SupervisorEvent{Action: SupervisorRecovered, Attempt: attempt})
+		}
+		if started || closed {
+			return started, closed
+		}
+		*restarts = append(*restarts, yd.clock.Now())
+		if err := yd.startSupervised(); errors.Is(err, errStopped) {
+			yd.log.Debug("Supervisor: restart is canceled by Stop")
+			return false, false
+		} else if err != nil {
+			yd.emit(SupervisorEvent{Action: SupervisorRestartFailed, Attempt: attempt, Err: err})
+			continue
+		}
+		yd.emit(SupervisorEvent{Action: SupervisorRestarted, Attempt: attempt})
+		return true, false
+	}
+}
+
+// wait waits for the delay. It returns started=true when the daemon was started by someone else
+// meanwhile and closed=true when the subscription is closed.
+func (yd *YDisk) wait(sub *Subscription, delay time.Duration) (started, closed bool) {
+	timer := yd.clock.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			return false, false
+		case yds, open := <-sub.C:
+			if !open {
+				return false, true
+			}
+			if running(yds.Stat) {
+				return true, false
+			}
+		}
+	}
+}
+
+// checkStopped reports whether the daemon is stopped by Stop and returns the current daemon status.
+// It waits for the completion of Stop that is in progress.
+func (yd *YDisk) checkStopped() (bool, output) {
+	yd.ctl.Lock()
+	defer yd.ctl.Unlock()
+	if yd.stopped {
+		return true, output{}
+	}
+	return false, yd.getOutput(false)
+}
+
+// startSupervised starts the daemon unless it is stopped by Stop (errStopped is returned then).
+func (yd *YDisk) startSupervised() error {
+	yd.ctl.Lock()
+	defer yd.ctl.Unlock()
+	if yd.stopped {
+		return errStopped
+	}
+	_, err := yd.startLocked(context.Background())
+	return err
+}
+
+// emit logs the supervisor event and sends it to SupervisorEvents channel
+func (yd *YDisk) emit(e SupervisorEvent) {
+	switch e.Action {
+	case SupervisorCrash, SupervisorRestartFailed, SupervisorGaveUp:
+		yd.log.Warning("Supervisor:", e.Action, "attempt:", e.Attempt, "error:", e.Err)
+	default:
+		yd.log.Info("Supervisor:", e.Action, "attempt:", e.Attempt, "delay:", e.Delay)
+	}
+	select {
+	case yd.supEvents <- e:
+	default:
+		yd.log.Warning("Supervisor event is dropped:", e.Action)
+	}
+}
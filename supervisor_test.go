@@ -0,0 +1,134 @@
+package ydisk
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// shiftClock is the real clock with shifted current time. Its timers fire in real time or when
+// the shift of time passes their deadlines.
+type shiftClock struct {
+	mu     sync.Mutex
+	shift  time.Duration
+	timers []*shiftTimer
+}
+
+type shiftTimer struct {
+	realTimer
+	clock    *shiftClock
+	deadline time.Time
+}
+
+func (c *shiftClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.shift)
+}
+
+func (c *shiftClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &shiftTimer{realTimer{time.NewTimer(d)}, c, time.Now().Add(c.shift + d)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// advance shifts the current time and fires the active timers with passed deadlines
+func (c *shiftClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shift += d
+	now := time.Now().Add(c.shift)
+	for _, t := range c.timers {
+		if !t.deadline.After(now) && t.realTimer.Stop() {
+			t.realTimer.Reset(0)
+		}
+	}
+}
+
+func (t *shiftTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	t.deadline = time.Now().Add(t.clock.shift + d)
+	t.clock.mu.Unlock()
+	return t.realTimer.Reset(d)
+}
+
+func TestSupervisor(t *testing.T) {
+	dir := t.TempDir()
+	conf, exe := fakeDaemon(t, dir, "d=\""+dir+"\"\n"+
+		"case \"$1\" in\n"+
+		"status) [ -f \"$d/running\" ] && echo \"Synchronization core status: idle\" || { echo \"Error: daemon not started\" >&2; exit 1; };;\n"+
+		"start) [ -f \"$d/broken\" ] && exit 1; touch \"$d/running\";;\n"+
+		"stop) [ -f \"$d/nostop\" ] && exit 4; rm -f \"$d/running\";;\n"+
+		"esac\n")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "running"), nil, 0600))
+	clock := &shiftClock{}
+	yd := newYDisk(conf, WithExecutable(exe), WithClock(clock), WithDelivery(DeliverLatest),
+		WithPolling(10*time.Millisecond, 10*time.Millisecond, 10*time.Millisecond),
+		WithSupervisor(SupervisorConfig{MinBackoff: 10 * time.Millisecond, MaxRestarts: 2}))
+	yd.Path = dir
+	yd.startSupervisor()
+	go yd.eventHandler()
+	isIdle := func() bool { return yd.Status().Stat == StatusIdle }
+	require.Eventually(t, isIdle, time.Second, 5*time.Millisecond)
+	next := func() SupervisorEvent {
+		select {
+		case e := <-yd.SupervisorEvents():
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("no supervisor event")
+		}
+		return SupervisorEvent{}
+	}
+	// crash of daemon
+	require.NoError(t, os.Remove(filepath.Join(dir, "running")))
+	require.Equal(t, SupervisorEvent{Action: SupervisorCrash}, next())
+	require.Equal(t, SupervisorEvent{Action: SupervisorRestart, Attempt: 1, Delay: 10 * time.Millisecond}, next())
+	require.Equal(t, SupervisorEvent{Action: SupervisorRestarted, Attempt: 1}, next())
+	require.Eventually(t, isIdle, time.Second, 5*time.Millisecond)
+	// stop by Stop is not a crash
+	require.NoError(t, yd.Stop())
+	require.Eventually(t, func() bool { return yd.Status().Stat == StatusNone }, time.Second, 5*time.Millisecond)
+	require.NoError(t, yd.Start())
+	require.Eventually(t, isIdle, time.Second, 5*time.Millisecond)
+	require.Empty(t, yd.SupervisorEvents())
+	// failed restart and the limit of restarts
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken"), nil, 0600))
+	require.NoError(t, os.Remove(filepath.Join(dir, "running")))
+	require.Equal(t, SupervisorCrash, next().Action)
+	require.Equal(t, SupervisorEvent{Action: SupervisorRestart, Attempt: 2, Delay: 20 * time.Millisecond}, next())
+	e := next()
+	require.Equal(t, SupervisorRestartFailed, e.Action)
+	require.ErrorIs(t, e.Err, ErrDaemonStartFailed)
+	require.Equal(t, SupervisorEvent{Action: SupervisorGaveUp, Attempt: 2}, next())
+	// start by someone else while restarts are paused
+	require.NoError(t, os.Remove(filepath.Join(dir, "broken")))
+	require.NoError(t, yd.Start())
+	require.Equal(t, SupervisorEvent{Action: SupervisorRecovered, Attempt: 2}, next())
+	require.Eventually(t, isIdle, time.Second, 5*time.Millisecond)
+	// the limit of restarts is still reached
+	require.NoError(t, os.Remove(filepath.Join(dir, "running")))
+	require.Equal(t, SupervisorCrash, next().Action)
+	require.Equal(t, SupervisorGaveUp, next().Action)
+	require.Empty(t, yd.SupervisorEvents())
+	// the restarts are resumed when the attempts are out of the window
+	clock.advance(10 * time.Minute)
+	require.Equal(t, SupervisorEvent{Action: SupervisorRestart, Attempt: 1, Delay: 10 * time.Millisecond}, next())
+	require.Equal(t, SupervisorEvent{Action: SupervisorRestarted, Attempt: 1}, next())
+	require.Eventually(t, isIdle, time.Second, 5*time.Millisecond)
+	// failed Stop doesn't disable the supervision
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nostop"), nil, 0600))
+	require.ErrorIs(t, yd.Stop(), ErrDaemonStopFailed)
+	require.NoError(t, os.Remove(filepath.Join(dir, "running")))
+	require.Equal(t, SupervisorCrash, next().Action)
+	require.Equal(t, SupervisorRestart, next().Action)
+	require.Equal(t, SupervisorRestarted, next().Action)
+	yd.Close()
+	_, ok := <-yd.SupervisorEvents()
+	require.False(t, ok)
+}
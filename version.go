@@ -0,0 +1,133 @@
+package ydisk
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version - the yandex-disk version (like 0.1.6.1080)
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Build int
+}
+
+var versionRe = regexp.MustCompile(`\d+(\.\d+){1,3}`)
+
+// ParseVersion finds and parses the version number in the output of `yandex-disk --version`.
+func ParseVersion(s string) (Version, error) {
+	m := versionRe.FindString(s)
+	if m == "" {
+		return Version{}, fmt.Errorf("no version number in: %q", s)
+	}
+	v := [4]int{}
+	for i, p := range strings.Split(m, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("wrong version number %q: %w", m, err)
+		}
+		v[i] = n
+	}
+	return Version{v[0], v[1], v[2], v[3]}, nil
+}
+
+// Compare returns -1, 0 or +1 when v is less, equal or greater than o.
+func (v Version) Compare(o Version) int {
+	for _, d := range [4]int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch, v.Build - o.Build} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// IsZero reports whether the version is unknown.
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Patch, v.Build)
+}
+
+// Capability - the daemon feature that is not available in all yandex-disk versions
+type Capability int
+
+// Daemon capabilities
+const (
+	CapPublish          Capability = iota // publish and unpublish commands
+	CapPublishOverwrite                   // --overwrite option of publish command
+	CapExcludeDirs                        // exclude-dirs option
+)
+
+func (c Capability) String() string {
+	switch c {
+	case CapPublish:
+		return "publish"
+	case CapPublishOverwrite:
+		return "publish --overwrite"
+	case CapExcludeDirs:
+		return "exclude-dirs"
+	}
+	return "capability(" + strconv.Itoa(int(c)) + ")"
+}
+
+// capabilities - the minimal yandex-disk versions known to support the capabilities. Only the
+// versions confirmed by the yandex-disk release notes have to be added here (with the reference
+// to the release), as the capability without minimal version is not checked. None of the
+// capabilities has the confirmed minimal version yet.
+var capabilities = map[Capability]Version{}
+
+// Supports reports whether the daemon version supports the capability. The unknown version is
+// considered as supporting everything, as well as the capability without known minimal version.
+func (v Version) Supports(c Capability) bool {
+	since, ok := capabilities[c]
+	return v.IsZero() || !ok || v.Compare(since) >= 0
+}
+
+// Version runs `yandex-disk --version` and returns the parsed daemon version.
+func (yd *YDisk) Version() (Version, error) {
+	cmd := yd.cmdline("--version")
+	out, _, err := yd.execute(context.Background(), cmd)
+	if err != nil {
+		return Version{}, err
+	}
+	v, err := ParseVersion(string(out))
+	if err != nil {
+		return Version{}, err
+	}
+	yd.mu.Lock()
+	yd.version = v
+	yd.mu.Unlock()
+	return v, nil
+}
+
+// Supports reports whether the installed daemon supports the capability. The version detected at
+// YDisk creation (or by the last Version call) is used. When the version is unknown Supports
+// returns true.
+func (yd *YDisk) Supports(c Capability) bool {
+	yd.mu.Lock()
+	defer yd.mu.Unlock()
+	return yd.version.Supports(c)
+}
+
+// require returns ErrUnsupported when the installed daemon doesn't support the capability.
+func (yd *YDisk) require(c Capability) error {
+	yd.mu.Lock()
+	v := yd.version
+	yd.mu.Unlock()
+	if v.Supports(c) {
+		return nil
+	}
+	err := fmt.Errorf("%w: %s requires yandex-disk %v or newer (installed %v)",
+		ErrUnsupported, c, capabilities[c], v)
+	yd.log.Error(err)
+	return err
+}
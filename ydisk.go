@@ -1,72 +1,188 @@
 /*
 Package ydisk implements API for yandex-disk daemon. Logging is organized
-via github.com/slytomcat/llog package.
+via github.com/slytomcat/llog package by default (see WithLogger).
 */
 package ydisk
 
 import (
-	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/slytomcat/llog"
 )
 
 // YDvals - Daemon Status structure
 type YDvals struct {
-	Stat   string   // Current Status
-	Prev   string   // Previous Status
-	Total  string   // Total space available
-	Used   string   // Used space
-	Free   string   // Free space
-	Trash  string   // Trash size
-	Last   []string // Last-updated files/folders list (10 or less items)
-	ChLast bool     // Indicator that Last was changed
-	Err    string   // Error status message
-	ErrP   string   // Error path
-	Prog   string   // Synchronization progress (when in busy status)
+	Stat     Status   // Current Status
+	Prev     Status   // Previous Status
+	Total    string   // Total space available
+	Used     string   // Used space
+	Free     string   // Free space
+	Trash    string   // Trash size
+	Last     []string // Last-updated files/folders list (10 or less items)
+	ChLast   bool     // Indicator that Last was changed
+	Err      string   // Error status message (the status command error for StatusUnavailable)
+	ErrP     string   // Error path
+	Prog     string   // Synchronization progress (when in busy status)
+	TotalB   int64    // Total space available in bytes
+	UsedB    int64    // Used space in bytes
+	FreeB    int64    // Free space in bytes
+	TrashB   int64    // Trash size in bytes
+	Progress Progress // Parsed synchronization progress (when in busy status)
+	StatRaw  string   // Current Status text as it was reported by daemon
+	Polling  bool     // Status is obtained by polling only as file watching is not available
+}
+
+// Progress - parsed synchronization progress
+type Progress struct {
+	Done    int64 // Synchronized bytes
+	Total   int64 // Total bytes to synchronize
+	Percent int   // Synchronization progress in percents
 }
 
 /* A new YDvals constsructor */
 func newYDvals() YDvals {
 	return YDvals{
-		Stat:   "unknown",
-		Prev:   "unknown",
-		Total:  "",
-		Used:   "",
-		Free:   "",
-		Trash:  "",
-		Last:   []string{},
-		ChLast: true,
-		Err:    "",
-		ErrP:   "",
-		Prog:   "",
+		Stat:     StatusUnknown,
+		Prev:     StatusUnknown,
+		Total:    "",
+		Used:     "",
+		Free:     "",
+		Trash:    "",
+		Last:     []string{},
+		ChLast:   true,
+		Err:      "",
+		ErrP:     "",
+		Prog:     "",
+		TotalB:   0,
+		UsedB:    0,
+		FreeB:    0,
+		TrashB:   0,
+		Progress: Progress{},
+		StatRaw:  "",
+		Polling:  false,
 	}
 }
 
 /* Tool function that controls the change of value in variable */
-func setChanged(v *string, val string, c *bool) {
+func setChanged[T comparable](v *T, val T, c *bool) {
 	if *v != val {
 		*v = val
 		*c = true
 	}
 }
 
+// sizeUnits - multipliers for size units used in the daemon output
+var sizeUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// ParseSize converts the size string from the daemon output (like "43.50 GB" or "654,48 MB")
+// into number of bytes. An empty string is converted to 0 without error.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n := strings.LastIndexFunc(s, unicode.IsDigit)
+	if n < 0 {
+		return 0, fmt.Errorf("wrong size value: %q", s)
+	}
+	mult, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(s[n+1:]))]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit in: %q", s)
+	}
+	num := strings.Map(func(r rune) rune {
+		switch {
+		case r == ',':
+			return '.' // comma is used as decimal separator in some locales
+		case unicode.IsSpace(r):
+			return -1 // drop spaces used as thousands separator
+		}
+		return r
+	}, s[:n+1])
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("wrong size value: %q: %w", s, err)
+	}
+	return int64(math.Round(v * mult)), nil
+}
+
+// ParseProgress converts the synchronization progress string from the daemon output
+// (like "139.38 MB/ 139.38 MB (100 %)") into Progress value. An empty string is
+// converted to zero Progress without error.
+func ParseProgress(s string) (Progress, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Progress{}, nil
+	}
+	d, rest, ok := strings.Cut(s, "/")
+	if !ok {
+		return Progress{}, fmt.Errorf("wrong progress value: %q", s)
+	}
+	t, pct, ok := strings.Cut(rest, "(")
+	if !ok {
+		return Progress{}, fmt.Errorf("wrong progress value: %q", s)
+	}
+	done, err := ParseSize(d)
+	if err != nil {
+		return Progress{}, err
+	}
+	total, err := ParseSize(t)
+	if err != nil {
+		return Progress{}, err
+	}
+	percent, err := strconv.Atoi(strings.TrimSpace(strings.TrimRight(pct, "% )")))
+	if err != nil {
+		return Progress{}, fmt.Errorf("wrong progress percent value: %q: %w", s, err)
+	}
+	return Progress{done, total, percent}, nil
+}
+
+// unavailable sets the status (StatusUnavailable or StatusTimeout) with the status command error.
+// It returns true when the status or the error is changed.
+func (val *YDvals) unavailable(stat Status, err error) bool {
+	val.Prev = val.Stat
+	changed := false
+	if setChanged(&val.Stat, stat, &changed); changed {
+		val.reset()
+	}
+	setChanged(&val.Err, err.Error(), &changed)
+	return changed
+}
+
+// reset clears all values except the status
+func (val *YDvals) reset() {
+	val.StatRaw = ""
+	val.Total, val.Used, val.Trash, val.Free = "", "", "", ""
+	val.Prog, val.Err, val.ErrP, val.ChLast = "", "", "", true
+	val.TotalB, val.UsedB, val.FreeB, val.TrashB = 0, 0, 0, 0
+	val.Progress = Progress{}
+	val.Last = []string{}
+}
+
 /* update - Updates Daemon status values from the daemon output string.
    Returns true if a change detected in any value, otherwise returns false */
 func (val *YDvals) update(out string) bool {
 	val.Prev = val.Stat // store previous status but don't track changes of val.Prev
 	changed := false    // track changes for values
 	if out == "" {
-		if setChanged(&val.Stat, "none", &changed); changed {
-			val.Total, val.Used, val.Trash, val.Free = "", "", "", ""
-			val.Prog, val.Err, val.ErrP, val.ChLast = "", "", "", true
-			val.Last = []string{}
+		if setChanged(&val.Stat, StatusNone, &changed); changed {
+			val.reset()
 		}
 		return changed
 	}
@@ -121,17 +237,23 @@ func (val *YDvals) update(out string) bool {
 	for k, v := range keys {
 		switch k {
 		case "Synchronization core status":
-			setChanged(&val.Stat, v, &changed)
+			setChanged(&val.Stat, ParseStatus(v), &changed)
+			setChanged(&val.StatRaw, v, &changed)
 		case "Total":
 			setChanged(&val.Total, v, &changed)
+			val.TotalB, _ = ParseSize(v)
 		case "Used":
 			setChanged(&val.Used, v, &changed)
+			val.UsedB, _ = ParseSize(v)
 		case "Available":
 			setChanged(&val.Free, v, &changed)
+			val.FreeB, _ = ParseSize(v)
 		case "Trash size":
 			setChanged(&val.Trash, v, &changed)
+			val.TrashB, _ = ParseSize(v)
 		case "Sync progress":
 			setChanged(&val.Prog, v, &changed)
+			val.Progress, _ = ParseProgress(v)
 		case "Error":
 			setChanged(&val.Err, v, &changed)
 		case "Path":
@@ -145,44 +267,139 @@ func (val *YDvals) update(out string) bool {
 	return changed || val.ChLast
 }
 
+// watcher watches the daemon log file (.sync/cli.log in synchronized folder) for changes.
+// It watches .sync folder (not the log file itself) to handle the log rotation/recreation. When
+// .sync folder doesn't exist the synchronized folder is watched for the .sync folder creation.
 type watcher struct {
 	*fsnotify.Watcher
-	active bool // Flag that means that watching path was successfully added
+	path    string // Path to synchronized folder
+	watched string // Currently watched folder ("" when nothing is watched)
+	log     Logger
 }
 
-func newwatcher() watcher {
-	watch, err := fsnotify.NewWatcher()
+// newwatcher creates the watcher via create function (fsnotify.NewWatcher by default)
+func newwatcher(create func() (*fsnotify.Watcher, error), path string, log Logger) (watcher, error) {
+	watch, err := create()
 	if err != nil {
-		llog.Critical(err)
+		return watcher{}, err
 	}
 	return watcher{
 		watch,
-		false,
+		path,
+		"",
+		log,
+	}, nil
+}
+
+// channels returns the watcher events and errors channels. Both are nil when watcher is not
+// available (receiving from nil channel blocks forever).
+func (w *watcher) channels() (chan fsnotify.Event, chan error) {
+	if w.Watcher == nil {
+		return nil, nil
 	}
+	return w.Events, w.Errors
 }
 
-func (w *watcher) activate(path string) {
-	if !w.active {
-		err := w.Add(filepath.Join(path, ".sync/cli.log"))
-		if err != nil {
-			llog.Debug("Watch path error:", err)
+func (w *watcher) close() {
+	if w.Watcher != nil {
+		w.Close()
+	}
+}
+
+// active reports whether the daemon log is watched
+func (w *watcher) active() bool {
+	return w.Watcher != nil && w.watched == filepath.Join(w.path, ".sync")
+}
+
+// activate starts watching of .sync folder or (when it doesn't exist) synchronized folder
+func (w *watcher) activate() {
+	if w.Watcher == nil || w.active() {
+		return
+	}
+	syncDir := filepath.Join(w.path, ".sync")
+	dir := syncDir
+	err := w.Add(syncDir)
+	if err != nil {
+		w.log.Debug("Watch path error:", err)
+		if w.watched == w.path {
+			return // already waiting for .sync creation
+		}
+		dir = w.path
+		if err = w.Add(w.path); err != nil {
+			w.log.Debug("Watch path error:", err)
 			return
 		}
-		llog.Debug("Watch path added")
-		w.active = true
 	}
+	if w.watched != "" {
+		w.Remove(w.watched)
+	}
+	w.watched = dir
+	w.log.Debug("Watch path added:", dir)
+}
+
+// handle processes the watcher event: it re-arms watching when .sync folder is created or
+// removed. It returns true when the event may be related to the daemon status change.
+func (w *watcher) handle(event fsnotify.Event) bool {
+	syncDir := filepath.Join(w.path, ".sync")
+	switch event.Name {
+	case filepath.Join(syncDir, "cli.log"):
+		return true
+	case syncDir:
+		if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			if w.watched == syncDir {
+				w.Remove(syncDir)
+				w.watched = ""
+			}
+		}
+		w.activate()
+		return true
+	case w.path:
+		if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			w.Remove(w.path)
+			w.watched = ""
+		}
+		return true
+	}
+	return false
 }
 
-// YDisk provides methods to interact with yandex-disk (methods: Start, Stop, Output), path
-// of synchronized catalogue (property Path) and channel for receiving yandex-disk status
+// YDisk provides methods to interact with yandex-disk (methods: Start, Stop, StartContext,
+// StopContext, Sync, Output, Publish, Unpublish), path of synchronized catalogue (property
+// Path), daemon configuration (property Config) and channel for receiving yandex-disk status
 // changes (property Changes).
 type YDisk struct {
-	Path     string        // Path to synchronized folder (obtained from yandex-disk conf. file)
-	Changes  chan YDvals   // Output channel for detected changes in daemon status
-	conf     string        // Path to yandex-disc configuration file
-	exe      string        // Path to yandex-disk executable
-	exit     chan struct{} // Stop signal/replay channel for Event handler routine
-	activate func()        // Function to activate watcher after daemon creation
+	Path       string                            // Path to synchronized folder (obtained from yandex-disk conf. file)
+	Config     *Config                           // Daemon configuration (parsed yandex-disk conf. file)
+	Changes    chan YDvals                       // Output channel for detected changes in daemon status
+	conf       string                            // Path to yandex-disc configuration file
+	exe        string                            // Path to yandex-disk executable
+	exeArgs    []string                          // Fixed arguments of executable (when it is a wrapper)
+	exit       chan struct{}                     // Stop signal/replay channel for Event handler routine
+	newWatcher func() (*fsnotify.Watcher, error) // File watcher constructor
+	runner     Runner                            // Executor of yandex-disk CLI commands
+	clock      Clock                             // Timers provider for event handler
+	log        Logger                            // Logger
+	minPoll    time.Duration                     // Minimal status polling interval
+	maxPoll    time.Duration                     // Maximal status polling interval
+	busyPoll   time.Duration                     // Status polling interval in busy/index status
+	lang       string                            // Language of Output ("" - the current user language)
+	cmdTimeout time.Duration                     // Timeout of yandex-disk commands (0 - no timeout)
+	mu         sync.Mutex                        // Protects stat, updated, closed and version
+	version    Version                           // The daemon version (zero when it is unknown)
+	stat       YDvals                            // The last status values observed by event handler
+	updated    chan struct{}                     // Closed (and replaced by new one) when event handler observes a change
+	closed     bool                              // Event handler is exited
+	pollReq    chan struct{}                     // Requests for immediate status check (and watching activation)
+	refresh    chan chan YDvals                  // Requests for immediate status check with reply of status values
+	changesBuf int                               // Buffer size of Changes
+	delivery   Delivery                          // Delivery mode of Changes
+	changes    *Subscription                     // Subscription that feeds Changes
+	subs       []*Subscription                   // Subscriptions for changes (protected by mu)
+	ctl        sync.Mutex                        // Serializes start/stop of daemon and protects stopped
+	stopped    bool                              // Daemon is stopped by Stop (it is not restarted by supervisor)
+	supervisor *SupervisorConfig                 // Supervisor configuration (nil - supervisor is disabled)
+	supEvents  chan SupervisorEvent              // Supervisor events
+	closeOnce  sync.Once                         // Makes Close idempotent
 }
 
 // NewYDisk creates new YDisk structure for communication with yandex-disk daemon
@@ -194,131 +411,702 @@ type YDisk struct {
 //  - check that yandex-disk was installed
 //  - check that yandex-disk was properly configured
 //
-// When something not good NewYDisk returns not nil error
+// When something not good NewYDisk returns not nil error.
+//
+// If the file watcher can't be created (e.g. inotify limits are exhausted) then YDisk works
+// in polling only mode (see YDvals.Polling) until the watcher creation succeeds.
 func NewYDisk(conf string) (*YDisk, error) {
-	exe, path, err := checkDaemon(conf)
-	if err != nil {
+	return NewYDiskWithOptions(conf)
+}
+
+// NewYDiskWithOptions creates new YDisk structure like NewYDisk does and applies the options to it.
+func NewYDiskWithOptions(conf string, opts ...Option) (*YDisk, error) {
+	yd := newYDisk(conf, opts...)
+	if yd.minPoll <= 0 || yd.maxPoll < yd.minPoll || yd.busyPoll <= 0 {
+		err := fmt.Errorf("wrong polling intervals: min %v, max %v, busy %v", yd.minPoll, yd.maxPoll, yd.busyPoll)
+		yd.log.Error(err)
 		return nil, err
 	}
-	watch := newwatcher()
-	llog.Debug("yandex-disk executable is:", exe)
-	yd := YDisk{
-		path,
-		make(chan YDvals, 1), // Output should be buffered
-		conf,
-		exe,
-		make(chan struct{}),
-		func() { watch.activate(path) },
+	if yd.changesBuf < 0 {
+		err := fmt.Errorf("wrong Changes buffer size: %d", yd.changesBuf)
+		yd.log.Error(err)
+		return nil, err
+	}
+	exe, cfg, err := checkDaemon(conf, yd.exe, yd.log)
+	if err != nil {
+		return nil, err
 	}
+	yd.log.Debug("yandex-disk executable is:", exe, yd.exeArgs)
+	yd.exe, yd.Config, yd.Path = exe, cfg, cfg.Dir
+	yd.detectVersion()
+	yd.startSupervisor()
 	// start event handler in separate goroutine
-	go yd.eventHandler(watch)
-	// Try to activate watching at the beginning. It may fail but it is not a problem
-	// as it can be activated later (on Start of daemon).
-	yd.activate()
-	llog.Debug("New YDisk created and initialized. Path:", path)
-	return &yd, nil
+	go yd.eventHandler()
+	yd.log.Debug("New YDisk created and initialized. Path:", yd.Path)
+	return yd, nil
+}
+
+// newYDisk creates new YDisk with default settings and applies the options to it. It neither
+// checks the daemon nor starts the event handler.
+func newYDisk(conf string, opts ...Option) *YDisk {
+	yd := &YDisk{
+		conf:       conf,
+		exit:       make(chan struct{}),
+		newWatcher: fsnotify.NewWatcher,
+		runner:     execRunner{},
+		clock:      realClock{},
+		log:        DefaultLogger,
+		minPoll:    time.Second,
+		maxPoll:    32 * time.Second,
+		busyPoll:   2 * time.Second,
+		cmdTimeout: 30 * time.Second,
+		changesBuf: 1, // Output should be buffered
+		stat:       newYDvals(),
+		updated:    make(chan struct{}),
+		pollReq:    make(chan struct{}, 1),
+		refresh:    make(chan chan YDvals),
+	}
+	for _, opt := range opts {
+		opt(yd)
+	}
+	yd.Changes = make(chan YDvals, max(yd.changesBuf, 0))
+	yd.changes = newSubscription(yd, yd.Changes, yd.delivery)
+	yd.Changes = yd.changes.ch
+	yd.subs = []*Subscription{yd.changes}
+	return yd
+}
+
+// rewatch creates new file watcher and tries to activate watching. It may fail to activate
+// watching but it is not a problem as it can be activated later (on Start of daemon).
+func (yd *YDisk) rewatch() watcher {
+	watch, err := newwatcher(yd.newWatcher, yd.Path, yd.log)
+	if err != nil {
+		// It is not fatal: daemon status still can be obtained by polling
+		yd.log.Warning("File watcher creation error (polling only mode):", err)
+		return watch
+	}
+	watch.activate()
+	return watch
 }
 
 // eventHandler works in separate goroutine until YDisk.exit channel receives a bool value (any).
-func (yd *YDisk) eventHandler(watch watcher) {
-	llog.Debug("Event handler started")
+// In case of watcher errors event handler closes the watcher and continues the status polling
+// until a new watcher is successfully created. The polling only mode is reported via YDvals.Polling.
+func (yd *YDisk) eventHandler() {
+	yd.log.Debug("Event handler started")
+	watch := yd.rewatch()
+	events, errs := watch.channels()
 	yds := newYDvals()
-	interval := 1
-	tick := time.NewTimer(time.Millisecond * 100) // First time trigger it quickly to update the current status
+	interval := yd.minPoll
+	tick := yd.clock.NewTimer(time.Millisecond * 100) // First time trigger it quickly to update the current status
 	defer func() {
-		watch.Close()
+		watch.close()
 		tick.Stop()
-		close(yd.Changes)
-		llog.Debug("Event handler exited")
+		yd.mu.Lock()
+		yd.closed = true
+		close(yd.updated)
+		subs := yd.subs
+		yd.subs = nil
+		yd.mu.Unlock()
+		for _, s := range subs {
+			s.close()
+		}
+		yd.log.Debug("Event handler exited")
 		yd.exit <- struct{}{} // Report exit completion
 	}()
 	for {
+		var reply chan YDvals
 		select {
-		case err := <-watch.Errors:
-			llog.Error("Watcher error:", err)
-			return
+		case err := <-errs:
+			yd.log.Error("Watcher error:", err)
+			watch.close()
+			watch = watcher{} // continue in polling only mode
+			events, errs = watch.channels()
+			interval = yd.minPoll
 		case <-yd.exit:
 			return
-		case event := <-watch.Events:
-			llog.Debug("Watcher event:", event)
-			interval = 1
-		case <-tick.C:
-			llog.Debug("Timer interval:", interval)
-			if yds.Stat == "busy" || yds.Stat == "index" {
-				interval = 2 // keep 2s interval in busy mode
+		case event := <-events:
+			if !watch.handle(event) {
+				continue // not related to daemon log
+			}
+			yd.log.Debug("Watcher event:", event)
+			interval = yd.minPoll
+		case <-yd.pollReq:
+			yd.log.Debug("Status check requested")
+			watch.activate()
+			interval = yd.minPoll
+		case reply = <-yd.refresh:
+			yd.log.Debug("Status refresh requested")
+			watch.activate()
+		case <-tick.C():
+			yd.log.Debug("Timer interval:", interval)
+			if watch.Watcher == nil {
+				if watch = yd.rewatch(); watch.Watcher != nil {
+					yd.log.Info("File watcher restored")
+					events, errs = watch.channels()
+				}
+			} else {
+				watch.activate() // .sync folder may be created without any event
+			}
+			if yds.Stat == StatusBusy || yds.Stat == StatusIndex {
+				interval = yd.busyPoll // keep busy interval (2s by default) in busy mode
 			} else {
-				if interval < 32 {
-					interval <<= 1 // continuously increase timer interval: 2s, 4s, 8s.
+				if interval < yd.maxPoll {
+					interval = min(interval*2, yd.maxPoll) // continuously increase timer interval: 2s, 4s, 8s.
 				}
 			}
 		}
 		// in both cases (Timer or Watcher events):
 		//  - restart timer
-		tick.Reset(time.Duration(interval) * time.Second)
+		tick.Reset(interval)
 		//  - check for daemon changes and send changed values in case of change
-		if yds.update(yd.getOutput(false)) {
-			llog.Debug("Change: ", yds.Prev, ">", yds.Stat,
-				"S", len(yds.Total) > 0, "L", len(yds.Last), "E", len(yds.Err) > 0)
-			yd.Changes <- yds
+		polling := yds.Polling
+		yds.Polling = watch.Watcher == nil
+		var changed bool
+		if out := yd.getOutput(false); out.state == outputTimeout {
+			changed = yds.unavailable(StatusTimeout, out.err)
+		} else if out.err != nil {
+			changed = yds.unavailable(StatusUnavailable, out.err)
+		} else {
+			changed = yds.update(out.text)
+		}
+		changed = changed || polling != yds.Polling
+		if changed {
+			yd.log.Debug("Change: ", yds.Prev, ">", yds.Stat,
+				"S", len(yds.Total) > 0, "L", len(yds.Last), "E", len(yds.Err) > 0, "P", yds.Polling)
+			yd.setStat(yds)
+		}
+		if reply != nil {
+			reply <- yd.Status() // reply is buffered
+		}
+		if changed {
+			if !yd.deliver(yds) {
+				return // Close is called while waiting for the consumer
+			}
 			// in case of any change reset the timer intrval
-			interval = 1
+			interval = yd.minPoll
+		}
+		//yd.log.Debug("Event processed")
+	}
+}
+
+// cmdline returns the full command line for running the daemon with args.
+func (yd *YDisk) cmdline(args ...string) []string {
+	return append(append([]string{yd.exe}, yd.exeArgs...), args...)
+}
+
+// detectVersion gets the version of daemon via `yandex-disk --version`.
+func (yd *YDisk) detectVersion() {
+	v, err := yd.Version()
+	if err != nil {
+		yd.log.Warning("yandex-disk version detection error:", err)
+		return
+	}
+	yd.log.Info("yandex-disk version:", v)
+}
+
+// setStat stores the status values observed by event handler and notifies the waiters.
+func (yd *YDisk) setStat(yds YDvals) {
+	yds.Last = append([]string{}, yds.Last...) // yds.Last is updated in place by event handler
+	yd.mu.Lock()
+	defer yd.mu.Unlock()
+	yd.stat = yds
+	close(yd.updated)
+	yd.updated = make(chan struct{})
+}
+
+// deliver sends the status values to all subscriptions. It returns false when the Close is
+// called while waiting for a consumer.
+func (yd *YDisk) deliver(yds YDvals) bool {
+	yd.mu.Lock()
+	subs := append([]*Subscription{}, yd.subs...)
+	yd.mu.Unlock()
+	for _, s := range subs {
+		if !s.deliver(yds) {
+			return false
+		}
+	}
+	return true
+}
+
+// Dropped returns the number of intermediate status changes that were replaced by the later
+// ones before the Changes consumer received them (in DeliverLatest mode).
+func (yd *YDisk) Dropped() int64 {
+	return yd.changes.Dropped()
+}
+
+// Status returns the last status values observed by event handler. It is safe to call it from
+// any goroutine.
+func (yd *YDisk) Status() YDvals {
+	yd.mu.Lock()
+	defer yd.mu.Unlock()
+	return yd.stat
+}
+
+// waitFor blocks until the status values observed by event handler satisfy the condition or
+// the context is done. It returns the last observed status values.
+func (yd *YDisk) waitFor(ctx context.Context, cond func(YDvals) bool) (YDvals, error) {
+	for {
+		yd.mu.Lock()
+		yds, updated, closed := yd.stat, yd.updated, yd.closed
+		yd.mu.Unlock()
+		if cond(yds) {
+			return yds, nil
+		}
+		if closed {
+			return yds, ErrClosed
 		}
-		//llog.Debug("Event processed")
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			return yds, ctx.Err()
+		}
+	}
+}
+
+// waitStatus blocks until the event handler observes the status or the context is done.
+func (yd *YDisk) waitStatus(ctx context.Context, status Status) error {
+	yds, err := yd.waitFor(ctx, func(yds YDvals) bool { return yds.Stat == status })
+	if err != nil {
+		err = &WaitError{status, yds, err}
+		yd.log.Error(err)
+	}
+	return err
+}
+
+// Refresh forces the immediate status check and returns the fresh status values. It returns
+// ErrClosed after Close and the context error when the context is done before the check.
+func (yd *YDisk) Refresh(ctx context.Context) (YDvals, error) {
+	if err := ctx.Err(); err != nil {
+		return YDvals{}, err
+	}
+	reply := make(chan YDvals, 1)
+	for sent := false; !sent; {
+		yd.mu.Lock()
+		closed, updated := yd.closed, yd.updated
+		yd.mu.Unlock()
+		if closed {
+			return YDvals{}, ErrClosed
+		}
+		select {
+		case yd.refresh <- reply:
+			sent = true
+		case <-updated: // recheck whether the event handler is exited
+		case <-ctx.Done():
+			return YDvals{}, ctx.Err()
+		}
+	}
+	select {
+	case yds := <-reply:
+		return yds, nil
+	case <-ctx.Done():
+		return YDvals{}, ctx.Err()
 	}
 }
 
-func (yd YDisk) getOutput(userLang bool) string {
-	cmd := []string{yd.exe, "status", "-c", yd.conf}
+// poll requests the event handler to check the daemon status as soon as possible.
+func (yd *YDisk) poll() {
+	select {
+	case yd.pollReq <- struct{}{}:
+	default: // the request is already pending
+	}
+}
+
+// outputState is the daemon state reported by `yandex-disk status` command
+type outputState int
+
+const (
+	outputRunning    outputState = iota // Daemon is running (the output contains its status)
+	outputNotRunning                    // Daemon reported that it is not running
+	outputFailed                        // Command can't be executed, it was terminated by signal or it failed by other reason
+	outputTimeout                       // Command was not completed in time
+)
+
+// output is the result of `yandex-disk status` command
+type output struct {
+	state outputState
+	text  string // Command output ("" when the daemon is not running or the command failed)
+	err   error  // Command failure (*DaemonError) for outputFailed and outputTimeout states
+}
+
+// notStarted is the message of status command when the daemon is not running (in C locale)
+const notStarted = "daemon not started"
+
+// getOutput runs the status command. The non zero exit code means that the daemon is not running
+// only when the command output contains the notStarted message (the localized output is not
+// checked), otherwise it is the command failure. The exit codes 126 and 127 of `env` wrapper
+// mean that the daemon executable can't be executed. The output in user language (userLang is
+// true) is only suitable for its text: any non zero exit code is considered as not running daemon,
+// so the decisions about the daemon state have to be made on the C locale output.
+func (yd *YDisk) getOutput(userLang bool) output {
+	cmd := yd.cmdline("status", "-c", yd.conf)
 	if !userLang {
 		cmd = append([]string{"env", "-i", "TEMP=" + os.TempDir()}, cmd...)
+	} else if yd.lang != "" {
+		cmd = append([]string{"env", "LANG=" + yd.lang, "LANGUAGE=" + yd.lang, "LC_ALL=" + yd.lang}, cmd...)
 	}
-	out, err := exec.Command(cmd[0], cmd[1:]...).Output()
-	if err != nil {
-		//llog.Debug("daemon status error:" + err.Error())
-		return ""
+	stdout, stderr, err := yd.execute(context.Background(), cmd)
+	if err == nil {
+		return output{outputRunning, string(stdout), nil}
+	}
+	res := newCommandResult("status", stdout, stderr, err)
+	switch {
+	case errors.Is(err, ErrTimeout):
+		yd.log.Warning("Daemon status timeout")
+		return output{outputTimeout, "", &DaemonError{res, err}}
+	case cmd[0] == "env" && (res.ExitCode == 126 || res.ExitCode == 127):
+		// env can't find or execute the daemon executable
+	case res.ExitCode > 0 && (userLang || strings.Contains(res.Output(), notStarted)):
+		return output{outputNotRunning, "", nil}
 	}
-	return string(out)
+	yd.log.Debug("Daemon status error:", err)
+	return output{outputFailed, "", &DaemonError{res, err}}
 }
 
 // Close deactivates the daemon connection: stops event handler that closes file watcher
-// and Changes channel.
+// and Changes channel. It is safe to call Close several times.
 func (yd *YDisk) Close() {
-	yd.exit <- struct{}{}
-	<-yd.exit // Wait for the event handler completion
+	yd.closeOnce.Do(func() {
+		yd.exit <- struct{}{}
+		<-yd.exit // Wait for the event handler completion
+	})
 }
 
-// Output returns the output string of `yandex-disk status` command in the current user language.
+// Output returns the output string of `yandex-disk status` command in the current user language
+// (or in the language set by WithOutputLanguage option).
 func (yd *YDisk) Output() string {
-	return yd.getOutput(true)
+	return yd.getOutput(true).text
 }
 
 // Start runs `yandex-disk start` if daemon was not started before.
 func (yd *YDisk) Start() error {
-	if yd.getOutput(true) == "" {
-		out, err := exec.Command(yd.exe, "start", "-c", yd.conf).Output()
-		if err != nil {
-			llog.Error(err)
-			return err
+	_, err := yd.start(context.Background())
+	return err
+}
+
+// StartResult runs `yandex-disk start` if daemon was not started before and returns the
+// captured result of the command (the result is zero when the daemon was already started).
+// The result of failed command is also available via *DaemonError.
+func (yd *YDisk) StartResult(ctx context.Context) (CommandResult, error) {
+	return yd.start(ctx)
+}
+
+// StartContext runs `yandex-disk start` if daemon was not started before and blocks until the
+// event handler observes the idle status of daemon or the context is done. If the idle status
+// was not reached the returned *WaitError contains the last observed status values.
+// Note that Changes channel (and other subscriptions in DeliverAll mode) have to be read
+// meanwhile as event handler waits for the delivery of each change.
+func (yd *YDisk) StartContext(ctx context.Context) error {
+	if _, err := yd.start(ctx); err != nil {
+		return err
+	}
+	return yd.waitStatus(ctx, StatusIdle)
+}
+
+func (yd *YDisk) start(ctx context.Context) (CommandResult, error) {
+	yd.ctl.Lock()
+	defer yd.ctl.Unlock()
+	return yd.startLocked(ctx)
+}
+
+// startLocked starts the daemon, the caller have to hold yd.ctl
+func (yd *YDisk) startLocked(ctx context.Context) (CommandResult, error) {
+	out := yd.getOutput(false)
+	if out.err != nil {
+		yd.log.Error(out.err)
+		return CommandResult{}, out.err
+	}
+	res := CommandResult{}
+	if out.state == outputNotRunning {
+		var err error
+		if res, err = yd.run(ctx, "start"); err != nil {
+			yd.log.Error("Daemon start failed:", res)
+			return res, err
 		}
-		llog.Debugf("Daemon start: %s", bytes.TrimRight(out, " \n"))
+		yd.log.Info("Daemon started:", res)
 	} else {
-		llog.Debug("Daemon already started")
+		yd.log.Debug("Daemon already started")
 	}
-	yd.activate() // try to activate watching after daemon start. It shouldn't fail on started daemon
-	return nil
+	yd.stopped = false
+	yd.poll() // it also activates watching that shouldn't fail on started daemon
+	return res, nil
 }
 
 // Stop runs `yandex-disk stop` if daemon was not stopped before.
 func (yd *YDisk) Stop() error {
-	if yd.getOutput(true) != "" {
-		out, err := exec.Command(yd.exe, "stop", "-c", yd.conf).Output()
-		if err != nil {
-			llog.Error(err)
-			return err
+	_, err := yd.stop(context.Background())
+	return err
+}
+
+// StopResult runs `yandex-disk stop` if daemon was not stopped before and returns the captured
+// result of the command (the result is zero when the daemon was already stopped).
+// The result of failed command is also available via *DaemonError.
+func (yd *YDisk) StopResult(ctx context.Context) (CommandResult, error) {
+	return yd.stop(ctx)
+}
+
+// StopContext runs `yandex-disk stop` if daemon was not stopped before and blocks until the
+// event handler observes that daemon is not running (status none) or the context is done. If
+// the none status was not reached the returned *WaitError contains the last observed status values.
+// Changes channel have to be read meanwhile (see StartContext).
+func (yd *YDisk) StopContext(ctx context.Context) error {
+	if _, err := yd.stop(ctx); err != nil {
+		return err
+	}
+	return yd.waitStatus(ctx, StatusNone)
+}
+
+func (yd *YDisk) stop(ctx context.Context) (CommandResult, error) {
+	yd.ctl.Lock()
+	defer yd.ctl.Unlock()
+	out := yd.getOutput(false)
+	if out.err != nil {
+		yd.log.Error(out.err)
+		return CommandResult{}, out.err
+	}
+	res := CommandResult{}
+	if out.state == outputRunning {
+		var err error
+		if res, err = yd.run(ctx, "stop"); err != nil {
+			yd.log.Error("Daemon stop failed:", res)
+			return res, err
 		}
-		llog.Debugf("Daemon stop: %s", bytes.TrimRight(out, " \n"))
+		yd.log.Info("Daemon stopped:", res)
 	} else {
-		llog.Debug("Daemon already stopped")
+		yd.log.Debug("Daemon already stopped")
+	}
+	yd.stopped = true // the supervisor should not restart the daemon
+	yd.poll()
+	return res, nil
+}
+
+// inSyncDir returns the absolute path for path that should be inside the synchronized folder.
+// Relative path is considered as relative to the synchronized folder.
+func (yd *YDisk) inSyncDir(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(yd.Path, path)
+	}
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(yd.Path, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrNotInSyncDir, path)
+	}
+	return path, nil
+}
+
+// execute runs the command line with the command timeout. The expired timeout is reported by
+// the error wrapping ErrTimeout.
+func (yd *YDisk) execute(ctx context.Context, cmdline []string) ([]byte, []byte, error) {
+	if yd.cmdTimeout <= 0 {
+		return yd.runner.Run(ctx, cmdline[0], cmdline[1:]...)
+	}
+	tctx, cancel := context.WithTimeout(ctx, yd.cmdTimeout)
+	defer cancel()
+	stdout, stderr, err := yd.runner.Run(tctx, cmdline[0], cmdline[1:]...)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w (%v)", ctx.Err(), err)
+	} else if err != nil && tctx.Err() != nil {
+		err = fmt.Errorf("%w after %v (%v)", ErrTimeout, yd.cmdTimeout, err)
 	}
+	return stdout, stderr, err
+}
+
+// command runs the daemon command with configured configuration file and returns its output.
+// Non successful run is reported by *DaemonError.
+func (yd *YDisk) command(ctx context.Context, cmd string, args ...string) (string, error) {
+	res, err := yd.run(ctx, cmd, args...)
+	return res.Stdout, err
+}
+
+// run runs the daemon command with configured configuration file and returns its captured
+// result. Non successful run is reported by *DaemonError that contains the same result.
+func (yd *YDisk) run(ctx context.Context, cmd string, args ...string) (CommandResult, error) {
+	cmdline := yd.cmdline(append([]string{cmd, "-c", yd.conf}, args...)...)
+	stdout, stderr, err := yd.execute(ctx, cmdline)
+	res := newCommandResult(cmd, stdout, stderr, err)
+	if err != nil {
+		return res, &DaemonError{res, err}
+	}
+	return res, nil
+}
+
+// Publish runs `yandex-disk publish` for the path inside synchronized folder and returns the
+// public link to the published file/folder.
+func (yd *YDisk) Publish(path string) (string, error) {
+	if err := yd.require(CapPublish); err != nil {
+		return "", err
+	}
+	path, err := yd.inSyncDir(path)
+	if err != nil {
+		return "", err
+	}
+	out, err := yd.command(context.Background(), "publish", path)
+	if err != nil {
+		yd.log.Error(err)
+		return "", err
+	}
+	for _, f := range strings.Fields(out) {
+		if strings.HasPrefix(f, "http://") || strings.HasPrefix(f, "https://") {
+			yd.log.Debugf("Published %s: %s", path, f)
+			return f, nil
+		}
+	}
+	err = &DaemonError{CommandResult{Cmd: "publish", Stdout: strings.TrimSpace(out)}, errors.New("no public link in output")}
+	yd.log.Error(err)
+	return "", err
+}
+
+// Unpublish runs `yandex-disk unpublish` for the path inside synchronized folder.
+func (yd *YDisk) Unpublish(path string) error {
+	if err := yd.require(CapPublish); err != nil {
+		return err
+	}
+	path, err := yd.inSyncDir(path)
+	if err != nil {
+		return err
+	}
+	out, err := yd.command(context.Background(), "unpublish", path)
+	if err != nil {
+		yd.log.Error(err)
+		return err
+	}
+	yd.log.Debugf("Unpublish %s: %s", path, strings.TrimSpace(out))
 	return nil
 }
+
+// Sync runs `yandex-disk sync` to force the synchronization. When wait is true Sync blocks until
+// the daemon returns to idle status: the status is checked right after the command and, if the
+// synchronization is still in progress, the event handler observations are awaited. The context
+// can be used to cancel the command or the waiting and to set the timeout.
+// Changes channel have to be read meanwhile (see StartContext).
+func (yd *YDisk) Sync(ctx context.Context, wait bool) error {
+	out, err := yd.command(ctx, "sync")
+	if err != nil {
+		yd.log.Error(err)
+		return err
+	}
+	yd.log.Debugf("Daemon sync: %s", strings.TrimSpace(out))
+	if !wait {
+		return nil
+	}
+	// the fast synchronization can be completed before the next event handler observation
+	yds, err := yd.Refresh(ctx)
+	if err != nil {
+		err = &WaitError{StatusIdle, yd.Status(), err}
+		yd.log.Error(err)
+		return err
+	}
+	if yds.Stat == StatusIdle {
+		return nil
+	}
+	return yd.waitStatus(ctx, StatusIdle)
+}
+
+// SaveConfig writes Config into the daemon configuration file. When restart is true and the
+// daemon is running it is restarted to apply the changes.
+// Note that the change of synchronized folder (Config.Dir) requires new YDisk creation.
+func (yd *YDisk) SaveConfig(restart bool) error {
+	if err := yd.Config.Save(yd.conf); err != nil {
+		yd.log.Error("Daemon configuration file saving error:", err)
+		return err
+	}
+	yd.log.Debug("Daemon configuration saved")
+	if !restart || yd.getOutput(false).state != outputRunning {
+		return nil
+	}
+	return yd.restart(context.Background())
+}
+
+// restart stops and starts the daemon
+func (yd *YDisk) restart(ctx context.Context) error {
+	if _, err := yd.stop(ctx); err != nil {
+		return err
+	}
+	_, err := yd.start(ctx)
+	return err
+}
+
+// ExcludedDirs returns the list of folders excluded from synchronization (relative to Path).
+func (yd *YDisk) ExcludedDirs() []string {
+	return append([]string{}, yd.Config.ExcludeDirs...)
+}
+
+// AddExcludedDirs adds folders to the list of excluded from synchronization folders. The dirs
+// have to be inside the synchronized folder (relative paths are considered as relative to Path).
+// The configuration is saved and running daemon is restarted only when the list is changed.
+func (yd *YDisk) AddExcludedDirs(dirs ...string) error {
+	if err := yd.require(CapExcludeDirs); err != nil {
+		return err
+	}
+	list := yd.ExcludedDirs()
+	for _, d := range dirs {
+		rel, err := yd.relDir(d)
+		if err != nil {
+			return err
+		}
+		if indexOfDir(list, rel) < 0 {
+			list = append(list, rel)
+		}
+	}
+	return yd.setExcludedDirs(list)
+}
+
+// RemoveExcludedDirs removes folders from the list of excluded from synchronization folders.
+// The configuration is saved and running daemon is restarted only when the list is changed.
+func (yd *YDisk) RemoveExcludedDirs(dirs ...string) error {
+	if err := yd.require(CapExcludeDirs); err != nil {
+		return err
+	}
+	list := yd.ExcludedDirs()
+	for _, d := range dirs {
+		rel, err := yd.relDir(d)
+		if err != nil {
+			return err
+		}
+		if i := indexOfDir(list, rel); i >= 0 {
+			list = append(list[:i], list[i+1:]...)
+		}
+	}
+	return yd.setExcludedDirs(list)
+}
+
+// relDir returns the path of folder relative to the synchronized folder.
+func (yd *YDisk) relDir(dir string) (string, error) {
+	path, err := yd.inSyncDir(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, _ := filepath.Rel(yd.Path, path) // the error is checked by inSyncDir
+	if rel == "." {
+		return "", fmt.Errorf("%w: the synchronized folder itself can't be excluded", ErrNotInSyncDir)
+	}
+	return rel, nil
+}
+
+func indexOfDir(list []string, dir string) int {
+	for i, d := range list {
+		if filepath.Clean(d) == dir {
+			return i
+		}
+	}
+	return -1
+}
+
+func (yd *YDisk) setExcludedDirs(list []string) error {
+	if slices.Equal(list, yd.Config.ExcludeDirs) {
+		yd.log.Debug("Excluded folders are not changed")
+		return nil
+	}
+	prev := yd.Config.ExcludeDirs
+	yd.Config.ExcludeDirs = list
+	if err := yd.Config.Save(yd.conf); err != nil {
+		yd.log.Error("Daemon configuration file saving error:", err)
+		yd.Config.ExcludeDirs = prev
+		return err
+	}
+	yd.log.Debug("Excluded folders:", list)
+	if yd.getOutput(false).state != outputRunning {
+		return nil
+	}
+	return yd.restart(context.Background())
+}
@@ -185,16 +185,16 @@ func (val *YDvals) update2(out string) bool {
 		}
 		keys[s[1]] = s[2]
 	}
+	setChanged(&val.Stat, Status(keys["Synchronization"]), &changed)
 	// map representation of switch_case clause
 	for k, v := range map[string]*string{
-		"Synchronization": &val.Stat,
-		"Total":           &val.Total,
-		"Used":            &val.Used,
-		"Available":       &val.Free,
-		"Trash":           &val.Trash,
-		"Error":           &val.Err,
-		"Path":            &val.ErrP,
-		"Sync":            &val.Prog,
+		"Total":     &val.Total,
+		"Used":      &val.Used,
+		"Available": &val.Free,
+		"Trash":     &val.Trash,
+		"Error":     &val.Err,
+		"Path":      &val.ErrP,
+		"Sync":      &val.Prog,
 	} {
 		setChanged(v, keys[k], &changed)
 	}
@@ -244,7 +244,7 @@ func (val *YDvals) update1(out string) bool {
 	for k, v := range keys {
 		switch k {
 		case "Synchronization core status":
-			setChanged(&val.Stat, v, &changed)
+			setChanged(&val.Stat, Status(v), &changed)
 		case "Total":
 			setChanged(&val.Total, v, &changed)
 		case "Used":
@@ -1,6 +1,8 @@
 package ydisk
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -8,9 +10,12 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/slytomcat/llog"
 	"github.com/stretchr/testify/require"
 )
@@ -31,6 +36,8 @@ func TestMain(m *testing.M) {
 	// Initialization
 	llog.SetLevel(llog.DEBUG)
 	llog.SetFlags(log.Lshortfile | log.Lmicroseconds)
+	DefaultLogger.SetLevel(llog.DEBUG)
+	DefaultLogger.SetFlags(log.Lshortfile | log.Lmicroseconds)
 	CfgPath = os.ExpandEnv(ConfigFilePath)
 	Cfg = filepath.Join(CfgPath, "config.cfg")
 	SyncDir = os.ExpandEnv(SyncDirPath)
@@ -40,44 +47,60 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal(CfgPath, " creation error:", err)
 	}
-
-	SymExe, err = exec.LookPath("yandex-disk")
-	if err != nil {
-		log.Fatal("yandex-disk utility lookup error:", err)
-	}
-
-	exec.Command(SymExe, "stop").Run()
-	os.RemoveAll(path.Join(os.TempDir(), "yandexdisksimulator.socket"))
-	log.Printf("Tests init completed: yd exe: %v", SymExe)
+	log.Println("Tests init completed")
 
 	// Run tests
 	e := m.Run()
 
 	// Clearance
-	exec.Command(SymExe, "stop").Run()
-	os.RemoveAll(path.Join(os.TempDir(), "yandexdisksimulator.socket"))
 	os.RemoveAll(CfgPath)
 	os.RemoveAll(SyncDir)
 	log.Println("Tests clearance completed")
 	os.Exit(e)
 }
 
+// simulator prepares the yandex-disk simulator for the test. The test is skipped when the
+// simulator is not installed (the tests with fake daemons don't need it).
+func simulator(t *testing.T) {
+	var err error
+	SymExe, err = exec.LookPath("yandex-disk")
+	if err != nil {
+		t.Skip("yandex-disk simulator is not installed:", err)
+	}
+	stop := func() {
+		exec.Command(SymExe, "stop").Run()
+		os.RemoveAll(path.Join(os.TempDir(), "yandexdisksimulator.socket"))
+	}
+	stop()
+	t.Cleanup(stop)
+	t.Log("yd exe:", SymExe)
+}
+
+// fakePath makes the fake daemon executable the only yandex-disk found in PATH
+func fakePath(t *testing.T) {
+	_, exe := fakeDaemon(t, t.TempDir(), notRunning)
+	t.Setenv("PATH", filepath.Dir(exe))
+}
+
 func TestNotInstalled(t *testing.T) {
 	t.Setenv("PATH", "")
 	// test not_installed case
 	yd, err := NewYDisk(Cfg)
-	require.Error(t, err)
+	require.ErrorIs(t, err, ErrNotInstalled)
 	require.Nil(t, yd)
 }
 
 func TestWrongConf(t *testing.T) {
+	fakePath(t)
 	// test initialization with wrong/not-existing config
 	yd, err := NewYDisk(Cfg + "_bad")
-	require.Error(t, err)
+	require.ErrorIs(t, err, ErrConfigUnreadable)
+	require.ErrorIs(t, err, os.ErrNotExist)
 	require.Nil(t, yd)
 }
 
 func TestEmptyConf(t *testing.T) {
+	fakePath(t)
 	// test initialization with empty config
 	file, err := os.OpenFile(Cfg, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
 	require.NoError(t, err)
@@ -87,10 +110,11 @@ func TestEmptyConf(t *testing.T) {
 	file.Close()
 	defer os.Remove(Cfg)
 	_, err = NewYDisk(Cfg)
-	require.Error(t, err)
+	require.ErrorIs(t, err, ErrNotConfigured)
 }
 
 func TestFull(t *testing.T) {
+	simulator(t)
 	// prepare for similation
 	err := exec.Command(SymExe, "setup").Run()
 	require.NoError(t, err)
@@ -110,7 +134,7 @@ func TestFull(t *testing.T) {
 		require.Eventually(t, func() bool {
 			select {
 			case yds = <-YD.Changes:
-				require.Equal(t, "{none unknown     [] true   }", fmt.Sprintf("%v", yds))
+				require.Equal(t, "{none unknown     [] true    0 0 0 0 {0 0 0}  false}", fmt.Sprintf("%v", yds))
 				return true
 			default:
 				return false
@@ -124,7 +148,7 @@ func TestFull(t *testing.T) {
 		require.Eventually(t, func() bool {
 			select {
 			case yds = <-YD.Changes:
-				require.Equal(t, "{paused none     [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] true   }", fmt.Sprintf("%v", yds))
+				require.Equal(t, "{paused none     [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] true    0 0 0 0 {0 0 0} paused false}", fmt.Sprintf("%v", yds))
 				return true
 			default:
 				return false
@@ -144,7 +168,7 @@ func TestFull(t *testing.T) {
 				if yds.Stat != "idle" {
 					return false
 				}
-				require.Equal(t, "{idle index 43.50 GB 2.89 GB 40.61 GB 0 B [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] false   }", fmt.Sprintf("%v", yds))
+				require.Equal(t, "{idle index 43.50 GB 2.89 GB 40.61 GB 0 B [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] false    46707769344 3103113871 43604655473 0 {0 0 0} idle false}", fmt.Sprintf("%v", yds))
 				return true
 			default:
 				return false
@@ -163,12 +187,12 @@ func TestFull(t *testing.T) {
 	})
 
 	t.Run("Sync", func(t *testing.T) {
-		err = exec.Command("yandex-disk", "sync").Run()
+		err = YD.Sync(context.Background(), false)
 		require.NoError(t, err)
 		select {
 		case yds = <-YD.Changes:
 			require.Equal(t,
-				"{index idle 43.50 GB 2.89 GB 40.61 GB 0 B [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] false   }",
+				"{index idle 43.50 GB 2.89 GB 40.61 GB 0 B [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] false    46707769344 3103113871 43604655473 0 {0 0 0} index false}",
 				fmt.Sprintf("%v", yds))
 		case <-time.After(2 * time.Second):
 			t.Fatal("no event for 2 seconds after sync command")
@@ -183,7 +207,7 @@ func TestFull(t *testing.T) {
 					return false
 				}
 				require.Equal(t,
-					"{idle index 43.50 GB 2.89 GB 40.61 GB 0 B [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] true   }",
+					"{idle index 43.50 GB 2.89 GB 40.61 GB 0 B [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] true    46707769344 3103113871 43604655473 0 {0 0 0} idle false}",
 					fmt.Sprintf("%v", yds))
 				return true
 			}
@@ -199,7 +223,7 @@ func TestFull(t *testing.T) {
 					return false
 				}
 				require.Equal(t,
-					"{error idle 43.50 GB 2.88 GB 40.62 GB 654.48 MB [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] false access error downloads/test1 }",
+					"{error idle 43.50 GB 2.88 GB 40.62 GB 654.48 MB [File.ods downloads/file.deb downloads/setup download down do_it very_very_long_long_file_with_underscore o w n] false access error downloads/test1  46707769344 3092376453 43615392891 686272020 {0 0 0} error false}",
 					fmt.Sprintf("%v", yds))
 				return true
 			default:
@@ -217,7 +241,7 @@ func TestFull(t *testing.T) {
 				if yds.Stat != "none" {
 					return false
 				}
-				require.Equal(t, "{none error     [] true   }", fmt.Sprintf("%v", yds))
+				require.Equal(t, "{none error     [] true    0 0 0 0 {0 0 0}  false}", fmt.Sprintf("%v", yds))
 				return true
 			default:
 				return false
@@ -250,3 +274,698 @@ func TestFull(t *testing.T) {
 		}, time.Second, 100*time.Millisecond)
 	})
 }
+
+func TestParseSize(t *testing.T) {
+	for s, exp := range map[string]int64{
+		"":          0,
+		"0 B":       0,
+		"12 KB":     12288,
+		"654.48 MB": 686272020,
+		"654,48 MB": 686272020,
+		"43.50 GB":  46707769344,
+		"1.5 TB":    1649267441664,
+	} {
+		v, err := ParseSize(s)
+		require.NoError(t, err, s)
+		require.Equal(t, exp, v, s)
+	}
+	for _, s := range []string{"GB", "12 PB", "1.2.3 MB"} {
+		_, err := ParseSize(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestParseProgress(t *testing.T) {
+	p, err := ParseProgress("")
+	require.NoError(t, err)
+	require.Equal(t, Progress{}, p)
+	p, err = ParseProgress("69.69 MB/ 139.38 MB (50 %)")
+	require.NoError(t, err)
+	require.Equal(t, Progress{73075261, 146150523, 50}, p)
+	for _, s := range []string{"139.38 MB", "139.38 MB/ 139.38 MB", "1 XB/ 2 MB (50 %)", "1 MB/ 2 MB (half)"} {
+		_, err = ParseProgress(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestYDvalsUpdateProgress(t *testing.T) {
+	yds := newYDvals()
+	require.True(t, yds.update("Sync progress: 139.38 MB/ 139.38 MB (100 %)\nSynchronization core status: busy\n\tTotal: 43.50 GB\n\tUsed: 2.89 GB\n\tAvailable: 40.61 GB\n\tTrash size: 0 B\n"))
+	require.Equal(t, Progress{146150523, 146150523, 100}, yds.Progress)
+	require.Equal(t, int64(46707769344), yds.TotalB)
+	require.True(t, yds.update("Synchronization core status: idle\n"))
+	require.Equal(t, Progress{}, yds.Progress)
+}
+
+func TestParseStatus(t *testing.T) {
+	for raw, exp := range map[string]Status{
+		"idle":               StatusIdle,
+		"busy":               StatusBusy,
+		"index":              StatusIndex,
+		"paused":             StatusPaused,
+		"error":              StatusError,
+		"no internet access": StatusNoNet,
+		"something new":      StatusUnrecognised,
+	} {
+		require.Equal(t, exp, ParseStatus(raw), raw)
+	}
+	yds := newYDvals()
+	require.True(t, yds.update("Synchronization core status: something new\n"))
+	require.Equal(t, StatusUnrecognised, yds.Stat)
+	require.Equal(t, "something new", yds.StatRaw)
+	require.True(t, yds.update(""))
+	require.Equal(t, StatusNone, yds.Stat)
+	require.Equal(t, StatusUnrecognised, yds.Prev)
+	require.Empty(t, yds.StatRaw)
+}
+
+func TestPublish(t *testing.T) {
+	yd := newYDisk("")
+	yd.Path = "/home/user/Yandex.Disk"
+	p, err := yd.inSyncDir("dir/file")
+	require.NoError(t, err)
+	require.Equal(t, "/home/user/Yandex.Disk/dir/file", p)
+	p, err = yd.inSyncDir("/home/user/Yandex.Disk/..file")
+	require.NoError(t, err)
+	require.Equal(t, "/home/user/Yandex.Disk/..file", p)
+	_, err = yd.Publish("/home/user/Yandex.Disk.bak/file")
+	require.ErrorIs(t, err, ErrNotInSyncDir)
+	err = yd.Unpublish("../file")
+	require.ErrorIs(t, err, ErrNotInSyncDir)
+	yd.exe = "false" // daemon refuses any command
+	_, err = yd.Publish("file")
+	var de *DaemonError
+	require.ErrorAs(t, err, &de)
+	require.Equal(t, "publish", de.Cmd)
+}
+
+func TestWaitFor(t *testing.T) {
+	yd := newYDisk("")
+	go func() {
+		for _, st := range []Status{StatusNone, StatusPaused, StatusIndex, StatusIdle} {
+			time.Sleep(10 * time.Millisecond)
+			yd.setStat(YDvals{Stat: st})
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, yd.waitStatus(ctx, StatusIndex))
+	require.NoError(t, yd.waitStatus(ctx, StatusIdle))
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := yd.waitStatus(ctx, StatusBusy)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	var we *WaitError
+	require.ErrorAs(t, err, &we)
+	require.Equal(t, StatusBusy, we.Want)
+	require.Equal(t, StatusIdle, we.Last.Stat)
+}
+
+func TestSyncWait(t *testing.T) {
+	yd, runner, tick := startHandler(t, "idle", WithDelivery(DeliverLatest))
+	defer yd.Close()
+	// the synchronization is completed before the status check
+	require.NoError(t, yd.Sync(context.Background(), true))
+	require.Contains(t, <-runner.calls, "sync")
+	require.Contains(t, <-runner.calls, "status")
+	// the synchronization is in progress after the command
+	runner.setStatus(statusOutput("busy"))
+	done := make(chan error)
+	go func() { done <- yd.Sync(context.Background(), true) }()
+	require.Contains(t, <-runner.calls, "sync")
+	require.Contains(t, <-runner.calls, "status")
+	tick("busy")
+	tick("idle")
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Sync is not completed")
+	}
+	runner.setStatus(statusOutput("busy"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var we *WaitError
+	require.ErrorAs(t, yd.Sync(ctx, true), &we)
+	require.Equal(t, StatusBusy, we.Last.Stat)
+}
+
+func TestStartStopContext(t *testing.T) {
+	yd, runner, tick := startHandler(t, "", WithDelivery(DeliverLatest))
+	defer yd.Close()
+	done := make(chan error)
+	go func() { done <- yd.StartContext(context.Background()) }()
+	require.Eventually(t, func() bool { return yd.Status().Stat == StatusIndex }, time.Second, time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("StartContext is completed before idle status")
+	default:
+	}
+	tick("idle")
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("StartContext is not completed")
+	}
+	require.NoError(t, yd.StopContext(context.Background()))
+	require.Equal(t, StatusNone, yd.Status().Stat)
+	res, err := yd.StartResult(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, CommandResult{"start", "Starting daemon process...Done", "", 0}, res)
+	res, err = yd.StartResult(context.Background()) // already started
+	require.NoError(t, err)
+	require.Equal(t, CommandResult{}, res)
+	res, err = yd.StopResult(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, CommandResult{"stop", "Daemon stopped.", "", 0}, res)
+	// the daemon can't be started
+	runner.setErr(errors.New("exec: permission denied"))
+	require.Error(t, yd.StartContext(context.Background()))
+	// the daemon is started but it doesn't reach idle status in time
+	runner.setErr(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var we *WaitError
+	require.ErrorAs(t, yd.StartContext(ctx), &we)
+	require.Equal(t, StatusIdle, we.Want)
+	require.Equal(t, StatusIndex, we.Last.Stat)
+	require.ErrorIs(t, we, context.DeadlineExceeded)
+}
+
+func TestExcludedDirs(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "config.cfg")
+	require.NoError(t, os.WriteFile(conf, []byte("dir=\"/home/user/Yandex.Disk\"\nexclude-dirs=\"build\"\n"), 0600))
+	cfg, err := ReadConfig(conf)
+	require.NoError(t, err)
+	yd := newYDisk(conf, WithExecutable("false")) // daemon is not running
+	yd.Path, yd.Config = cfg.Dir, cfg
+	require.Equal(t, []string{"build"}, yd.ExcludedDirs())
+	require.NoError(t, yd.AddExcludedDirs("/home/user/Yandex.Disk/cache/", "build", "tmp/../obj"))
+	require.Equal(t, []string{"build", "cache", "obj"}, yd.ExcludedDirs())
+	require.ErrorIs(t, yd.AddExcludedDirs("/tmp/cache"), ErrNotInSyncDir)
+	require.ErrorIs(t, yd.AddExcludedDirs("."), ErrNotInSyncDir)
+	require.NoError(t, yd.RemoveExcludedDirs("build", "not-excluded"))
+	require.Equal(t, []string{"cache", "obj"}, yd.ExcludedDirs())
+	saved, err := ReadConfig(conf)
+	require.NoError(t, err)
+	require.Equal(t, []string{"cache", "obj"}, saved.ExcludeDirs)
+}
+
+func TestWatcherError(t *testing.T) {
+	dir := t.TempDir()
+	_, exe := fakeDaemon(t, dir, notRunning)
+	yd := newYDisk("", WithExecutable(exe))
+	yd.Path = dir
+	var mu sync.Mutex
+	watchers := []*fsnotify.Watcher{}
+	failures := 1 // the first watcher creation fails
+	yd.newWatcher = func() (*fsnotify.Watcher, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return nil, errors.New("too many open files")
+		}
+		w, err := fsnotify.NewWatcher()
+		watchers = append(watchers, w)
+		return w, err
+	}
+	go yd.eventHandler()
+	var yds YDvals
+	select {
+	case yds = <-yd.Changes:
+		require.Equal(t, StatusNone, yds.Stat)
+		require.False(t, yds.Polling) // watcher is restored on the first tick
+	case <-time.After(time.Second):
+		t.Fatal("no initial event")
+	}
+	mu.Lock()
+	require.Len(t, watchers, 1)
+	failures = 1 // the next watcher creation fails too
+	w := watchers[0]
+	mu.Unlock()
+	w.Errors <- errors.New("watcher failure")
+	select {
+	case yds = <-yd.Changes:
+		require.Equal(t, StatusNone, yds.Stat)
+		require.True(t, yds.Polling)
+	case <-time.After(time.Second):
+		t.Fatal("no event after watcher error")
+	}
+	select {
+	case yds = <-yd.Changes:
+		require.Equal(t, StatusNone, yds.Stat)
+		require.False(t, yds.Polling)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher is not restored")
+	}
+	mu.Lock()
+	require.Len(t, watchers, 2)
+	mu.Unlock()
+	yd.Close()
+	_, ok := <-yd.Changes
+	require.False(t, ok)
+}
+
+func TestWatcherRearm(t *testing.T) {
+	dir := t.TempDir()
+	syncDir := filepath.Join(dir, ".sync")
+	log := filepath.Join(syncDir, "cli.log")
+	w, err := newwatcher(fsnotify.NewWatcher, dir, DefaultLogger)
+	require.NoError(t, err)
+	defer w.close()
+	// handled returns the result of handling of the first event for the name
+	handled := func(name string) bool {
+		for {
+			select {
+			case e := <-w.Events:
+				if r := w.handle(e); e.Name == name {
+					return r
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("no event for %s", name)
+			}
+		}
+	}
+	w.activate()
+	require.False(t, w.active())
+	require.Equal(t, dir, w.watched)
+	require.NoError(t, os.Mkdir(syncDir, 0755))
+	require.True(t, handled(syncDir))
+	require.True(t, w.active())
+	require.NoError(t, os.WriteFile(log, []byte("started"), 0644))
+	require.True(t, handled(log))
+	require.NoError(t, os.WriteFile(filepath.Join(syncDir, "other"), []byte("data"), 0644))
+	require.False(t, handled(filepath.Join(syncDir, "other")))
+	require.NoError(t, os.Rename(log, log+".1")) // log rotation
+	require.True(t, handled(log))
+	require.NoError(t, os.WriteFile(log, []byte("new log"), 0644))
+	require.True(t, handled(log))
+	require.NoError(t, os.RemoveAll(syncDir))
+	require.True(t, handled(syncDir))
+	require.False(t, w.active())
+	require.Equal(t, dir, w.watched)
+	require.NoError(t, os.Mkdir(syncDir, 0755)) // recreation of .sync (e.g. after `yandex-disk setup`)
+	require.True(t, handled(syncDir))
+	require.True(t, w.active())
+}
+
+// notRunning is the fake daemon script that reports that the daemon is not running
+const notRunning = "echo \"Error: daemon not started\" >&2; exit 1\n"
+
+// fakeDaemon creates the daemon configuration for the synchronized folder dir (it is created
+// when it doesn't exist) and the daemon executable: the shell script with given body.
+func fakeDaemon(t *testing.T, dir, script string) (string, string) {
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	tmp := t.TempDir()
+	auth := filepath.Join(tmp, "passwd")
+	require.NoError(t, os.WriteFile(auth, []byte("token"), 0600))
+	conf := filepath.Join(tmp, "config.cfg")
+	require.NoError(t, os.WriteFile(conf, []byte("dir=\""+dir+"\"\nauth=\""+auth+"\"\n"), 0600))
+	exe := filepath.Join(tmp, "yandex-disk")
+	require.NoError(t, os.WriteFile(exe, []byte("#!/bin/sh\n"+script), 0700))
+	return conf, exe
+}
+
+// fakeRunner returns the predefined output for `status` command and reports the calls. The
+// `start` command makes the daemon indexing and `stop` command makes it not running.
+type fakeRunner struct {
+	mu     sync.Mutex
+	status string
+	err    error // error of command execution (e.g. not found executable)
+	calls  chan []string
+}
+
+func (r *fakeRunner) setStatus(status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *fakeRunner) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *fakeRunner) Run(_ context.Context, name string, args ...string) ([]byte, []byte, error) {
+	r.mu.Lock()
+	status, err := r.status, r.err
+	if err == nil && slices.Contains(args, "start") {
+		r.status = statusOutput("index")
+		status = "Starting daemon process...Done"
+	} else if err == nil && slices.Contains(args, "stop") {
+		r.status = ""
+		status = "Daemon stopped."
+	}
+	r.mu.Unlock()
+	r.calls <- append([]string{name}, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status == "" {
+		return nil, []byte("Error: daemon not started"), exec.Command("sh", "-c", "exit 1").Run()
+	}
+	return []byte(status), nil, nil
+}
+
+// fakeClock creates the timer that fires only by test request and reports its resets
+type fakeClock struct {
+	timer *fakeTimer
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.timer.resets <- d
+	return c.timer
+}
+
+func (c *fakeClock) Now() time.Time {
+	return time.Now()
+}
+
+type fakeTimer struct {
+	c      chan time.Time
+	resets chan time.Duration
+}
+
+func (t *fakeTimer) C() <-chan time.Time        { return t.c }
+func (t *fakeTimer) Reset(d time.Duration) bool { t.resets <- d; return true }
+func (t *fakeTimer) Stop() bool                 { return true }
+
+// statusOutput returns the daemon output with given status ("" - daemon is not running)
+func statusOutput(status string) string {
+	if status == "" {
+		return ""
+	}
+	return "Synchronization core status: " + status + "\n"
+}
+
+// startHandler creates YDisk with fakeRunner (it reports the given status) and fakeClock and
+// starts its event handler. The returned tick function sets the status reported by runner,
+// fires the timer and returns the timer reset interval after the status check.
+func startHandler(t *testing.T, status string, opts ...Option) (*YDisk, *fakeRunner, func(status string) time.Duration) {
+	runner := &fakeRunner{status: statusOutput(status), calls: make(chan []string, 100)}
+	clock := &fakeClock{&fakeTimer{make(chan time.Time), make(chan time.Duration, 10)}}
+	yd := newYDisk("conf", append([]Option{WithExecutable("yandex-disk"), WithRunner(runner), WithClock(clock)}, opts...)...)
+	yd.Path = t.TempDir()
+	go yd.eventHandler()
+	require.Equal(t, 100*time.Millisecond, <-clock.timer.resets)
+	tick := func(status string) time.Duration {
+		runner.setStatus(statusOutput(status))
+		clock.timer.c <- time.Now()
+		d := <-clock.timer.resets
+		require.Contains(t, <-runner.calls, "status")
+		return d
+	}
+	return yd, runner, tick
+}
+
+func TestPollingIntervals(t *testing.T) {
+	yd, _, tick := startHandler(t, "idle")
+	defer yd.Close()
+	require.Equal(t, 2*time.Second, tick("idle"))
+	require.Equal(t, StatusIdle, (<-yd.Changes).Stat)
+	for _, exp := range []int{2, 4, 8, 16, 32, 32} {
+		require.Equal(t, time.Duration(exp)*time.Second, tick("idle"))
+	}
+	require.Equal(t, 32*time.Second, tick("busy"))
+	require.Equal(t, StatusBusy, (<-yd.Changes).Stat)
+	require.Equal(t, 2*time.Second, tick("busy"))
+	require.Equal(t, 2*time.Second, tick("busy"))
+	require.Equal(t, 2*time.Second, tick(""))
+	yds := <-yd.Changes
+	require.Equal(t, StatusNone, yds.Stat)
+	require.Equal(t, StatusBusy, yds.Prev)
+	require.Equal(t, 2*time.Second, tick(""))
+	require.Equal(t, 4*time.Second, tick(""))
+}
+
+func TestDelivery(t *testing.T) {
+	for _, d := range []Delivery{DeliverAll, DeliverLatest} {
+		yd, _, tick := startHandler(t, "idle", WithChangesBuffer(0), WithDelivery(d))
+		tick("idle") // DeliverAll: the event handler waits for the consumer after it
+		if d == DeliverLatest {
+			// the event handler is not blocked by the consumer
+			tick("busy")
+			tick("index")
+			tick("index")
+			yds := <-yd.Changes
+			require.Equal(t, StatusIndex, yds.Stat)
+			require.Equal(t, StatusBusy, yds.Prev)
+			require.Equal(t, int64(2), yd.Dropped())
+		}
+		done := make(chan struct{})
+		go func() {
+			yd.Close() // it doesn't depend on the consumer
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Close is blocked by the consumer")
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	yd, _, tick := startHandler(t, "")
+	all := yd.Subscribe(10, DeliverAll)
+	latest := yd.Subscribe(0, DeliverLatest)
+	tick("idle")
+	require.Equal(t, StatusIdle, (<-yd.Changes).Stat)
+	require.Equal(t, StatusIdle, (<-all.C).Stat)
+	require.Equal(t, StatusIdle, (<-latest.C).Stat)
+	all.Unsubscribe()
+	_, ok := <-all.C
+	require.False(t, ok)
+	all.Unsubscribe() // repeated call is harmless
+	tick("busy")
+	tick("index")
+	require.Equal(t, StatusBusy, (<-yd.Changes).Stat)
+	require.Equal(t, StatusIndex, (<-yd.Changes).Stat)
+	require.Eventually(t, func() bool { return latest.Dropped() == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, StatusIndex, (<-latest.C).Stat)
+	yd.Close()
+	_, ok = <-latest.C
+	require.False(t, ok)
+	_, ok = <-yd.Subscribe(1, DeliverAll).C
+	require.False(t, ok)
+}
+
+func TestStatusRefresh(t *testing.T) {
+	yd, runner, _ := startHandler(t, "idle")
+	require.Equal(t, newYDvals(), yd.Status())
+	yds, err := yd.Refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StatusIdle, yds.Stat)
+	require.Equal(t, yds, yd.Status())
+	require.Equal(t, yds, <-yd.Changes)
+	runner.setStatus(statusOutput("busy"))
+	yds, err = yd.Refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StatusBusy, yds.Stat)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = yd.Refresh(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	yd.Close()
+	_, err = yd.Refresh(context.Background())
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestStatusUnavailable(t *testing.T) {
+	yd, runner, _ := startHandler(t, "")
+	runner.setStatus("Synchronization core status: idle\n\tTotal: 1 KB\n")
+	defer yd.Close()
+	yds, _ := yd.Refresh(context.Background())
+	require.Equal(t, StatusIdle, yds.Stat)
+	require.Equal(t, StatusIdle, (<-yd.Changes).Stat)
+	// the missing executable is not reported as stopped daemon
+	runner.setErr(&os.PathError{Op: "fork/exec", Path: "yandex-disk", Err: os.ErrNotExist})
+	yds, _ = yd.Refresh(context.Background())
+	require.Equal(t, StatusUnavailable, yds.Stat)
+	require.Equal(t, StatusIdle, yds.Prev)
+	require.Contains(t, yds.Err, "file does not exist")
+	require.Empty(t, yds.Total)
+	require.Equal(t, yds, <-yd.Changes)
+	require.ErrorIs(t, yd.Start(), os.ErrNotExist)
+	require.ErrorIs(t, yd.Stop(), os.ErrNotExist)
+	// non zero exit code without the daemon message is the command failure
+	runner.setErr(exec.Command("sh", "-c", "exit 1").Run())
+	yds, _ = yd.Refresh(context.Background())
+	require.Equal(t, StatusUnavailable, yds.Stat)
+	require.Contains(t, yds.Err, "exit status 1")
+	// non zero exit code with the daemon message means that daemon is not running
+	runner.setErr(nil)
+	runner.setStatus("")
+	yds, _ = yd.Refresh(context.Background())
+	require.Equal(t, StatusNone, yds.Stat)
+	require.Empty(t, yds.Err)
+}
+
+func TestStatusNotExecutable(t *testing.T) {
+	dir := t.TempDir()
+	conf, exe := fakeDaemon(t, dir, "")
+	require.NoError(t, os.Chmod(exe, 0600))
+	for _, path := range []string{filepath.Join(dir, "not-exists"), exe} {
+		// the status is checked via `env -i` wrapper
+		yd := newYDisk(conf, WithExecutable(path))
+		yd.Path = dir
+		go yd.eventHandler()
+		yds, err := yd.Refresh(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, StatusUnavailable, yds.Stat, path)
+		require.NotEmpty(t, yds.Err)
+		yd.Close()
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	dir := t.TempDir()
+	conf, exe := fakeDaemon(t, dir, "sleep 10\n")
+	yd := newYDisk(conf, WithExecutable(exe), WithCommandTimeout(100*time.Millisecond),
+		WithPolling(time.Hour, time.Hour, time.Hour))
+	yd.Path = dir
+	go yd.eventHandler()
+	defer yd.Close()
+	yds, err := yd.Refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StatusTimeout, yds.Stat)
+	require.Contains(t, yds.Err, ErrTimeout.Error())
+	require.ErrorIs(t, yd.Start(), ErrTimeout)
+	_, err = yd.Version()
+	require.ErrorIs(t, err, ErrTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = yd.command(ctx, "sync")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotErrorIs(t, err, ErrTimeout)
+}
+
+func TestOptions(t *testing.T) {
+	dir := t.TempDir()
+	// daemon is not running
+	conf, exe := fakeDaemon(t, dir, notRunning)
+	_, err := NewYDiskWithOptions(conf, WithPolling(2*time.Second, time.Second, time.Second))
+	require.Error(t, err)
+	_, err = NewYDiskWithOptions(conf, WithExecutable(exe), WithChangesBuffer(-1))
+	require.Error(t, err)
+	yd, err := NewYDiskWithOptions(conf,
+		WithExecutable(exe),
+		WithChangesBuffer(5),
+		WithPolling(100*time.Millisecond, time.Second, 200*time.Millisecond),
+		WithOutputLanguage("C"),
+		WithLogger(llog.New(os.Stderr, "test ", log.Lmicroseconds, llog.DEBUG)),
+	)
+	require.NoError(t, err)
+	defer yd.Close()
+	require.Equal(t, dir, yd.Path)
+	require.Equal(t, exe, yd.exe)
+	require.Equal(t, 5, cap(yd.Changes))
+	require.Empty(t, yd.Output())
+	select {
+	case yds := <-yd.Changes:
+		require.Equal(t, StatusNone, yds.Stat)
+	case <-time.After(time.Second):
+		t.Fatal("no initial event")
+	}
+}
+
+func TestExecutable(t *testing.T) {
+	dir := t.TempDir()
+	// the wrapper requires the fixed argument before the daemon command
+	conf, wrapper := fakeDaemon(t, dir, "[ \"$1\" = \"--inner\" ] || exit 2\n"+
+		"[ \"$2\" = \"--version\" ] && echo \"Yandex.Disk 0.1.6.1080\\nCopyright\" || exit 1\n")
+	_, err := NewYDiskWithOptions(conf, WithExecutable(filepath.Join(dir, "not-exists")))
+	require.ErrorIs(t, err, ErrNotInstalled)
+	_, err = NewYDiskWithOptions(conf, WithExecutable(conf)) // not executable
+	require.Error(t, err)
+	yd, err := NewYDiskWithOptions(conf, WithExecutable(wrapper, "--inner"))
+	require.NoError(t, err)
+	defer yd.Close()
+	require.Equal(t, Version{0, 1, 6, 1080}, yd.version)
+}
+
+func TestStartStopFailed(t *testing.T) {
+	dir := t.TempDir()
+	conf, exe := fakeDaemon(t, dir, "case \"$1\" in\n"+
+		"start) echo \"Error: wrong auth\" >&2; exit 3;;\n"+
+		"status) [ -f \""+dir+"/started\" ] && echo \"Synchronization core status: idle\" || { echo \"Error: daemon not started\" >&2; exit 1; };;\n"+
+		"stop) exit 4;;\n"+
+		"esac\n")
+	yd := newYDisk(conf, WithExecutable(exe))
+	yd.Path = dir
+	err := yd.Start()
+	require.ErrorIs(t, err, ErrDaemonStartFailed)
+	require.NotErrorIs(t, err, ErrDaemonStopFailed)
+	var derr *DaemonError
+	require.ErrorAs(t, err, &derr)
+	require.Equal(t, 3, derr.ExitCode)
+	require.Equal(t, "Error: wrong auth", derr.Stderr)
+	require.Empty(t, derr.Stdout)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "started"), nil, 0600))
+	err = yd.Stop()
+	require.ErrorIs(t, err, ErrDaemonStopFailed)
+	require.ErrorAs(t, err, &derr)
+	require.Equal(t, 4, derr.ExitCode)
+}
+
+func TestStartStopStatusFailed(t *testing.T) {
+	dir := t.TempDir()
+	conf, exe := fakeDaemon(t, dir, "case \"$1\" in\n"+
+		"status) echo \"Error: permission denied on socket\" >&2; exit 1;;\n"+
+		"*) touch \""+dir+"/called\";;\n"+
+		"esac\n")
+	yd := newYDisk(conf, WithExecutable(exe))
+	yd.Path = dir
+	var derr *DaemonError
+	require.ErrorAs(t, yd.Stop(), &derr)
+	require.Equal(t, 1, derr.ExitCode)
+	require.Equal(t, "Error: permission denied on socket", derr.Stderr)
+	require.False(t, yd.stopped)
+	require.ErrorAs(t, yd.Start(), &derr)
+	require.NoFileExists(t, filepath.Join(dir, "called"))
+}
+
+func TestCommandResult(t *testing.T) {
+	res := newCommandResult("start", []byte("Starting daemon process...Done\n"), []byte(" \n"), nil)
+	require.Equal(t, CommandResult{"start", "Starting daemon process...Done", "", 0}, res)
+	require.Equal(t, "Starting daemon process...Done", res.Output())
+	err := exec.Command("sh", "-c", "exit 5").Run()
+	res = newCommandResult("stop", nil, []byte("Error: lock file\n"), err)
+	require.Equal(t, 5, res.ExitCode)
+	require.Equal(t, `yandex-disk stop: exit code 5, stdout: "", stderr: "Error: lock file"`, res.String())
+	require.Equal(t, -1, newCommandResult("stop", nil, nil, context.DeadlineExceeded).ExitCode)
+}
+
+func TestVersion(t *testing.T) {
+	v, err := ParseVersion("Yandex.Disk 0.1.5.1039\nCopyright (c) Yandex")
+	require.NoError(t, err)
+	require.Equal(t, Version{0, 1, 5, 1039}, v)
+	require.Equal(t, "0.1.5.1039", v.String())
+	v2, err := ParseVersion("yandex-disk 0.1.6")
+	require.NoError(t, err)
+	require.Equal(t, 1, v2.Compare(v))
+	require.Equal(t, -1, v.Compare(v2))
+	require.Equal(t, 0, v.Compare(v))
+	_, err = ParseVersion("unknown")
+	require.Error(t, err)
+	require.True(t, Version{0, 1, 0, 0}.Supports(CapExcludeDirs)) // no known minimal version
+	capabilities[CapPublish] = Version{0, 1, 2, 0}
+	capabilities[CapExcludeDirs] = Version{0, 1, 4, 0}
+	defer func() {
+		delete(capabilities, CapPublish)
+		delete(capabilities, CapExcludeDirs)
+	}()
+	require.True(t, Version{}.Supports(CapExcludeDirs))
+	require.True(t, v.Supports(CapExcludeDirs))
+	require.False(t, Version{0, 1, 3, 0}.Supports(CapExcludeDirs))
+	yd := newYDisk("")
+	yd.Path = "/home/user/Yandex.Disk"
+	yd.version = Version{0, 1, 0, 0}
+	_, err = yd.Publish("file")
+	require.ErrorIs(t, err, ErrUnsupported)
+	require.ErrorIs(t, yd.AddExcludedDirs("build"), ErrUnsupported)
+}